@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// configFlags связывает ключи файла конфигурации с флагами, которым они
+// задают значения.
+var configFlags = map[string]string{
+	"general.data_dir":                     "data-dir",
+	"performance.snapshot_threshold":       "snapshot-threshold",
+	"performance.snapshot_threshold_bytes": "snapshot-threshold-bytes",
+	"performance.snapshot_trailing_logs":   "snapshot-trailing-logs",
+	"reads.lease_clock_drift":              "lease-clock-drift",
+}
+
+// loadConfig читает TOML-файл и выставляет из него флаги, не заданные в
+// командной строке. Понимает секции и строки key = value со строковыми,
+// числовыми и булевыми значениями; ключи без флага пропускает.
+func loadConfig(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	explicit := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+
+	section := ""
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		switch {
+		case text == "" || strings.HasPrefix(text, "#"):
+			continue
+		case strings.HasPrefix(text, "[") && strings.HasSuffix(text, "]"):
+			section = strings.TrimSpace(text[1 : len(text)-1])
+			continue
+		}
+
+		key, value, ok := strings.Cut(text, "=")
+		if !ok {
+			return fmt.Errorf("%s:%d: expected key = value", path, line)
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			value = unquoted
+		}
+
+		name, ok := configFlags[section+"."+key]
+		if !ok || explicit[name] {
+			continue
+		}
+		if err := flag.Set(name, value); err != nil {
+			return fmt.Errorf("%s:%d: %s: %v", path, line, key, err)
+		}
+	}
+	return scanner.Err()
+}
@@ -16,33 +16,90 @@ import (
 	"github.com/gorilla/mux"
 	"google.golang.org/grpc"
 
+	"raft-kv-store/monitoring"
 	"raft-kv-store/pkg/api"
 	"raft-kv-store/pkg/kvstore"
 	"raft-kv-store/pkg/raft"
+	pb "raft-kv-store/proto"
 )
 
 var (
-	nodeID   = flag.Int("id", 1, "Unique node ID")
-	httpPort = flag.String("http", "8080", "HTTP server port")
-	grpcPort = flag.String("grpc", "9090", "gRPC server port")
-	peers    = flag.String("peers", "", "Comma-separated list of peer addresses")
+	configPath = flag.String("config", "", "TOML config file; flags given on the command line take precedence")
+
+	nodeID    = flag.Int("id", 1, "Unique node ID")
+	httpPort  = flag.String("http", "8080", "HTTP server port")
+	grpcPort  = flag.String("grpc", "9090", "gRPC server port")
+	advertise = flag.String("advertise", "", "gRPC address advertised to peers and clients (default hostname:grpc)")
+	peers     = flag.String("peers", "", "Comma-separated list of peer addresses, optionally as id=addr")
+	join      = flag.Bool("join", false, "Start outside the cluster and wait to be added via /cluster/members")
+	dataDir   = flag.String("data-dir", "/var/lib/raft", "Directory for the write-ahead log and snapshots")
+
+	snapshotThreshold      = flag.Int("snapshot-threshold", 100000, "Applied entries between snapshots")
+	snapshotThresholdBytes = flag.Int64("snapshot-threshold-bytes", 64<<20, "Appended log bytes between snapshots")
+	snapshotTrailingLogs   = flag.Int("snapshot-trailing-logs", 10240, "Entries kept in the log after a snapshot")
+
+	leaseClockDrift   = flag.Duration("lease-clock-drift", raft.DefaultLeaseClockDrift, "Clock drift bound subtracted from the leader lease")
+	learnerPromoteLag = flag.Int("learner-promote-lag", raft.DefaultLearnerPromoteLag, "Entries a learner may trail the commit index before it is promoted to voter")
 )
 
 func main() {
 	flag.Parse()
+	if *configPath != "" {
+		if err := loadConfig(*configPath); err != nil {
+			log.Fatalf("Failed to load config: %v", err)
+		}
+	}
 
-	raftNode := raft.NewRaftNode(*nodeID, parsePeers(*peers))
+	raftNode, err := raft.NewDurableRaftNode(*nodeID, parsePeers(*peers), *dataDir)
+	if err != nil {
+		log.Fatalf("Failed to open raft log: %v", err)
+	}
+	raftNode.SetLeaseClockDrift(*leaseClockDrift)
+	raftNode.SetLearnerPromoteLag(*learnerPromoteLag)
+	raftNode.SetRPCAddr(advertiseAddr())
+	if *join {
+		raftNode.SetJoining()
+	}
 
+	_, err = raft.NewSnapshotStore(raftNode, *dataDir, true, raft.SnapshotConfig{
+		Threshold:      *snapshotThreshold,
+		ThresholdBytes: *snapshotThresholdBytes,
+		TrailingLogs:   *snapshotTrailingLogs,
+	})
+	if err != nil {
+		log.Fatalf("Failed to load snapshots: %v", err)
+	}
 	store := kvstore.NewStore(raftNode)
 
 	go raftNode.Run()
+	monitoring.RegisterMetrics(raftNode)
 
 	router := mux.NewRouter()
 	apiServer := api.NewHTTPServer(store, raftNode)
 
 	router.HandleFunc("/key/{key}", apiServer.HandleGetKey).Methods("GET")
 	router.HandleFunc("/key/{key}", apiServer.HandlePutKey).Methods("PUT")
+	router.HandleFunc("/key/{key}", apiServer.HandleDeleteKey).Methods("DELETE")
+	router.HandleFunc("/txn", apiServer.HandleTxn).Methods("POST")
+	router.HandleFunc("/keys", apiServer.HandleListKeys).Methods("GET")
+	router.HandleFunc("/watch", apiServer.HandleWatch).Methods("GET")
+	router.HandleFunc("/compact", apiServer.HandleCompact).Methods("POST")
+	router.HandleFunc("/lease", apiServer.HandleLeaseGrant).Methods("POST")
+	router.HandleFunc("/lease/{id}", apiServer.HandleLeaseTimeToLive).Methods("GET")
+	router.HandleFunc("/lease/{id}", apiServer.HandleLeaseRevoke).Methods("DELETE")
+	router.HandleFunc("/lease/{id}/keepalive", apiServer.HandleLeaseKeepAlive).Methods("POST")
+	router.HandleFunc("/lock/{name}", apiServer.HandleLock).Methods("POST")
+	router.HandleFunc("/lock/{name}", apiServer.HandleUnlock).Methods("DELETE")
+	router.HandleFunc("/semaphore/{name}", apiServer.HandleSemaphoreAcquire).Methods("POST")
+	router.HandleFunc("/semaphore/{name}", apiServer.HandleSemaphoreRelease).Methods("DELETE")
+	router.HandleFunc("/election/{name}/campaign", apiServer.HandleCampaign).Methods("POST")
+	router.HandleFunc("/election/{name}", apiServer.HandleResign).Methods("DELETE")
+	router.HandleFunc("/election/{name}/leader", apiServer.HandleElectionLeader).Methods("GET")
 	router.HandleFunc("/cluster/status", apiServer.HandleClusterStatus).Methods("GET")
+	router.Handle("/metrics", monitoring.Handler()).Methods("GET")
+	router.HandleFunc("/cluster/members", apiServer.HandleAddMember).Methods("POST")
+	router.HandleFunc("/cluster/members/{id}", apiServer.HandleRemoveMember).Methods("DELETE")
+	router.HandleFunc("/cluster/transfer-leader", apiServer.HandleTransferLeader).Methods("POST")
 
 	httpServer := &http.Server{
 		Addr:         ":" + *httpPort,
@@ -52,7 +109,9 @@ func main() {
 	}
 
 	grpcServer := grpc.NewServer()
-	raft.RegisterRaftServer(grpcServer, api.NewRaftService(raftNode))
+	pb.RegisterRaftServiceServer(grpcServer, api.NewRaftService(raftNode))
+	pb.RegisterKeyValueServiceServer(grpcServer, api.NewKeyValueService(store, raftNode))
+	pb.RegisterConcurrencyServiceServer(grpcServer, api.NewConcurrencyService(store, raftNode))
 
 	var wg sync.WaitGroup
 	wg.Add(2)
@@ -86,10 +145,34 @@ func main() {
 	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
 	defer cancel()
 
-	grpcServer.GracefulStop()
+	// Передаём лидерство заранее, чтобы кластер не ждал таймаута выборов
+	if raftNode.IsLeader() {
+		transferCtx, transferCancel := context.WithTimeout(ctx, 5*time.Second)
+		if err := raftNode.TransferLeadership(transferCtx, 0); err != nil {
+			log.Printf("Leadership transfer failed: %v", err)
+		}
+		transferCancel()
+	}
+
+	// Watch-стримы сами не завершаются: закрываем их до остановки серверов,
+	// иначе GracefulStop и Shutdown ждут их до таймаута
+	store.Close()
+
+	stopped := make(chan struct{})
+	go func() {
+		grpcServer.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		log.Println("gRPC graceful stop timed out, closing connections")
+		grpcServer.Stop()
+	}
 
 	if err := httpServer.Shutdown(ctx); err != nil {
 		log.Printf("HTTP shutdown error: %v", err)
+		httpServer.Close()
 	}
 
 	raftNode.Stop()
@@ -97,6 +180,17 @@ func main() {
 	log.Println("Server stopped gracefully")
 }
 
+func advertiseAddr() string {
+	if *advertise != "" {
+		return *advertise
+	}
+	host, err := os.Hostname()
+	if err != nil {
+		host = "localhost"
+	}
+	return net.JoinHostPort(host, *grpcPort)
+}
+
 func parsePeers(peersStr string) []string {
 	if peersStr == "" {
 		return []string{}
@@ -1,12 +1,14 @@
 package monitoring
 
 import (
+	"net/http"
 	"raft-kv-store/pkg/raft"
 	"strconv"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/common/expfmt"
 )
 
 var (
@@ -42,6 +44,12 @@ var (
 		Name: "raft_rpc_errors_total",
 		Help: "Total number of RPC errors",
 	}, []string{"node_id", "type"})
+
+	// Метрики чтений
+	raftLeaseReads = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "raft_lease_reads_total",
+		Help: "Total number of lease reads by lease state (valid, expired)",
+	}, []string{"node_id", "result"})
 )
 
 func RegisterMetrics(node *raft.RaftNode) {
@@ -57,6 +65,26 @@ func RegisterMetrics(node *raft.RaftNode) {
 	}()
 }
 
+// Handler отдаёт метрики в текстовом формате Prometheus.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		families, err := prometheus.DefaultGatherer.Gather()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		format := expfmt.NewFormat(expfmt.TypeTextPlain)
+		w.Header().Set("Content-Type", string(format))
+		enc := expfmt.NewEncoder(w, format)
+		for _, family := range families {
+			if err := enc.Encode(family); err != nil {
+				return
+			}
+		}
+	})
+}
+
 func updateMetrics(node *raft.RaftNode) {
 	nodeID := strconv.Itoa(node.ID())
 
@@ -76,4 +104,9 @@ func updateMetrics(node *raft.RaftNode) {
 	for typ, count := range stats.Errors {
 		raftRpcErrors.WithLabelValues(nodeID, typ).Add(float64(count))
 	}
+
+	// Чтения
+	lease := node.LeaseReadStats()
+	raftLeaseReads.WithLabelValues(nodeID, "valid").Add(float64(lease.Valid))
+	raftLeaseReads.WithLabelValues(nodeID, "expired").Add(float64(lease.Expired))
 }
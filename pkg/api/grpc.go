@@ -9,6 +9,8 @@ import (
 	pb "raft-kv-store/proto"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type RaftServiceServer struct {
@@ -23,15 +25,51 @@ func NewRaftService(raftNode *raft.RaftNode) *RaftServiceServer {
 }
 
 func (s *RaftServiceServer) RequestVote(ctx context.Context, req *pb.RequestVoteRequest) (*pb.RequestVoteResponse, error) {
-	var reply pb.RequestVoteResponse
-	s.raftNode.HandleRequestVote(req, &reply)
-	return &reply, nil
+	args := raft.RequestVoteArgs{
+		Term:               int(req.Term),
+		CandidateID:        int(req.CandidateId),
+		LastLogIndex:       int(req.LastLogIndex),
+		LastLogTerm:        int(req.LastLogTerm),
+		LeadershipTransfer: req.LeadershipTransfer,
+	}
+	var reply raft.RequestVoteReply
+	s.raftNode.HandleRequestVote(&args, &reply)
+	return &pb.RequestVoteResponse{Term: uint64(reply.Term), VoteGranted: reply.VoteGranted}, nil
+}
+
+func (s *RaftServiceServer) RequestPreVote(ctx context.Context, req *pb.RequestVoteRequest) (*pb.RequestVoteResponse, error) {
+	args := raft.RequestVoteArgs{
+		Term:         int(req.Term),
+		CandidateID:  int(req.CandidateId),
+		LastLogIndex: int(req.LastLogIndex),
+		LastLogTerm:  int(req.LastLogTerm),
+	}
+	var reply raft.RequestVoteReply
+	s.raftNode.HandleRequestPreVote(&args, &reply)
+	return &pb.RequestVoteResponse{Term: uint64(reply.Term), VoteGranted: reply.VoteGranted}, nil
 }
 
 func (s *RaftServiceServer) AppendEntries(ctx context.Context, req *pb.AppendEntriesRequest) (*pb.AppendEntriesResponse, error) {
-	var reply pb.AppendEntriesResponse
-	s.raftNode.HandleAppendEntries(req, &reply)
-	return &reply, nil
+	args, err := raft.AppendEntriesFromProto(req)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	var reply raft.AppendEntriesReply
+	s.raftNode.HandleAppendEntries(args, &reply)
+	return &pb.AppendEntriesResponse{
+		Term:          uint64(reply.Term),
+		Success:       reply.Success,
+		ConflictIndex: uint64(reply.ConflictIndex),
+		ConflictTerm:  uint64(reply.ConflictTerm),
+	}, nil
+}
+
+func (s *RaftServiceServer) InstallSnapshot(stream pb.RaftService_InstallSnapshotServer) error {
+	return s.raftNode.HandleInstallSnapshot(stream)
+}
+
+func (s *RaftServiceServer) TimeoutNow(ctx context.Context, req *pb.TimeoutNowRequest) (*pb.TimeoutNowResponse, error) {
+	return s.raftNode.HandleTimeoutNow(req), nil
 }
 
 func StartGRPCServer(raftNode *raft.RaftNode, addr string) {
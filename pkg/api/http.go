@@ -1,8 +1,14 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"raft-kv-store/pkg/kvstore"
 	"raft-kv-store/pkg/raft"
@@ -10,6 +16,8 @@ import (
 	"github.com/gorilla/mux"
 )
 
+const watchHeartbeatInterval = 15 * time.Second
+
 type HTTPServer struct {
 	store    *kvstore.Store
 	raftNode *raft.RaftNode
@@ -26,40 +34,595 @@ func (s *HTTPServer) HandleGetKey(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	key := vars["key"]
 
-	value, err := s.store.Get(key)
+	query := r.URL.Query()
+	opts, err := kvstore.ParseReadOptions(query.Get("consistency"), query.Get("max_lag"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if opts.Revision, err = parseRevision(query.Get("revision")); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), kvstore.DefaultProposeTimeout)
+	defer cancel()
+
+	value, err := s.store.GetConsistent(ctx, key, opts)
 	if err != nil {
-		if err == kvstore.ErrKeyNotFound {
+		switch err {
+		case kvstore.ErrKeyNotFound:
 			http.Error(w, "Key not found", http.StatusNotFound)
+		case kvstore.ErrCompacted:
+			http.Error(w, err.Error(), http.StatusGone)
+		case kvstore.ErrFutureRevision:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case raft.ErrNotLeader, raft.ErrTooStale:
+			s.writeNotLeader(w, err)
+		case raft.ErrReadTimeout:
+			http.Error(w, err.Error(), http.StatusGatewayTimeout)
+		case raft.ErrReadIndexNotReady, raft.ErrLeadershipLost:
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, value)
+}
+
+func (s *HTTPServer) HandleListKeys(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	opts, err := kvstore.ParseReadOptions(query.Get("consistency"), query.Get("max_lag"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if opts.Revision, err = parseRevision(query.Get("revision")); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	limit := 0
+	if l := query.Get("limit"); l != "" {
+		if limit, err = strconv.Atoi(l); err != nil || limit < 0 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
 			return
 		}
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+
+	start, end := kvstore.ScanBounds(query.Get("prefix"), query.Get("start"), query.Get("end"))
+	if token := query.Get("token"); token != "" {
+		if start, err = kvstore.DecodeToken(token); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), kvstore.DefaultProposeTimeout)
+	defer cancel()
+
+	page, err := s.store.RangeConsistent(ctx, start, end, limit, opts)
+	if err != nil {
+		switch err {
+		case kvstore.ErrCompacted:
+			http.Error(w, err.Error(), http.StatusGone)
+		case kvstore.ErrFutureRevision:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case raft.ErrNotLeader, raft.ErrTooStale:
+			s.writeNotLeader(w, err)
+		case raft.ErrReadTimeout:
+			http.Error(w, err.Error(), http.StatusGatewayTimeout)
+		case raft.ErrReadIndexNotReady, raft.ErrLeadershipLost:
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
 		return
 	}
 
-	respondWithJSON(w, http.StatusOK, map[string]string{"value": value})
+	respondWithJSON(w, http.StatusOK, page)
 }
 
 func (s *HTTPServer) HandlePutKey(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	key := vars["key"]
 
-	value := r.URL.Query().Get("value")
+	query := r.URL.Query()
+	value := query.Get("value")
 	if value == "" {
 		http.Error(w, "Value is required", http.StatusBadRequest)
 		return
 	}
 
-	if err := s.store.Propose(key, value); err != nil {
-		if err == raft.ErrNotLeader {
-			leader := s.raftNode.GetLeader()
-			http.Redirect(w, r, leader+"/key/"+key, http.StatusTemporaryRedirect)
+	var leaseID int
+	if l := query.Get("lease"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n <= 0 {
+			http.Error(w, "Invalid lease", http.StatusBadRequest)
+			return
+		}
+		leaseID = n
+	}
+
+	base, err := requestContext(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(base, kvstore.DefaultProposeTimeout)
+	defer cancel()
+
+	var index int
+	switch {
+	case query.Has("prev_value"):
+		index, err = s.store.CompareAndSwap(ctx, key, query.Get("prev_value"), value, leaseID)
+	case query.Get("if_absent") == "true":
+		index, err = s.store.PutIfAbsent(ctx, key, value, leaseID)
+	case leaseID > 0:
+		index, err = s.store.PutWithLease(ctx, key, value, leaseID)
+	default:
+		index, err = s.store.Propose(ctx, key, value)
+	}
+	if err != nil {
+		switch err {
+		case kvstore.ErrConflict:
+			respondWithJSON(w, http.StatusPreconditionFailed,
+				map[string]interface{}{"error": err.Error(), "version": index})
+		case kvstore.ErrLeaseNotFound:
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case raft.ErrNotLeader:
+			s.writeNotLeader(w, err)
+		default:
+			writeProposeError(w, err)
+		}
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]int{"index": index})
+}
+
+func (s *HTTPServer) HandleDeleteKey(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	key := vars["key"]
+
+	var version int
+	if v := r.URL.Query().Get("version"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "Invalid version", http.StatusBadRequest)
+			return
+		}
+		version = n
+	}
+
+	base, err := requestContext(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(base, kvstore.DefaultProposeTimeout)
+	defer cancel()
+
+	var index int
+	if version > 0 {
+		index, err = s.store.DeleteIfVersion(ctx, key, version)
+	} else {
+		index, err = s.store.Delete(ctx, key)
+	}
+	if err != nil {
+		switch err {
+		case kvstore.ErrKeyNotFound:
+			http.Error(w, "Key not found", http.StatusNotFound)
+		case kvstore.ErrConflict:
+			respondWithJSON(w, http.StatusPreconditionFailed,
+				map[string]interface{}{"error": err.Error(), "version": index})
+		case raft.ErrNotLeader:
+			s.writeNotLeader(w, err)
+		default:
+			writeProposeError(w, err)
+		}
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]int{"index": index})
+}
+
+func (s *HTTPServer) HandleTxn(w http.ResponseWriter, r *http.Request) {
+	var txn kvstore.Txn
+	if err := json.NewDecoder(r.Body).Decode(&txn); err != nil {
+		http.Error(w, "Invalid transaction body", http.StatusBadRequest)
+		return
+	}
+	if err := txn.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	base, err := requestContext(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(base, kvstore.DefaultProposeTimeout)
+	defer cancel()
+
+	result, err := s.store.Txn(ctx, txn)
+	if err != nil {
+		switch err {
+		case kvstore.ErrLeaseNotFound:
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case raft.ErrNotLeader:
+			s.writeNotLeader(w, err)
+		default:
+			writeProposeError(w, err)
+		}
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, result)
+}
+
+// HandleWatch отдаёт события как Server-Sent Events. id события —
+// "<revision>.<номер в ревизии>": EventSource при переподключении присылает
+// его в Last-Event-ID, и поток продолжается с той же ревизии без пропусков
+// и повторов, даже если ревизия изменила несколько ключей.
+func (s *HTTPServer) HandleWatch(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	key, prefix := query.Get("key"), false
+	if p, ok := query["prefix"]; ok {
+		key, prefix = p[0], true
+	}
+
+	revision, err := parseRevision(query.Get("revision"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	skip := 0
+	if last := r.Header.Get("Last-Event-ID"); last != "" {
+		if rev, n, ok := parseEventID(last); ok {
+			revision, skip = rev, n
+		}
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	watcher, err := s.store.Watch(key, prefix, revision)
+	if err == kvstore.ErrCompacted {
+		http.Error(w, err.Error(), http.StatusGone)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer watcher.Close()
+
+	// WriteTimeout сервера не должен обрывать долгий стрим
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(watchHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	current, seq := 0, 0
+
+	for {
+		select {
+		case <-r.Context().Done():
 			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+		case ev, ok := <-watcher.Events():
+			if !ok {
+				fmt.Fprintf(w, "event: error\ndata: %s\n\n", watcher.Err())
+				flusher.Flush()
+				return
+			}
+			if ev.Revision != current {
+				current, seq = ev.Revision, 0
+			}
+			seq++
+			// Эти события клиент получил до переподключения
+			if ev.Revision == revision && seq <= skip {
+				continue
+			}
+			data, _ := json.Marshal(ev)
+			fmt.Fprintf(w, "id: %d.%d\nevent: %s\ndata: %s\n\n", ev.Revision, seq, ev.Type, data)
+		}
+		flusher.Flush()
+	}
+}
+
+// HandleCompact удаляет версии старше revision на всех узлах.
+func (s *HTTPServer) HandleCompact(w http.ResponseWriter, r *http.Request) {
+	revision, err := parseRevision(r.URL.Query().Get("revision"))
+	if err != nil || revision == 0 {
+		http.Error(w, "Invalid revision", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), kvstore.DefaultProposeTimeout)
+	defer cancel()
+
+	index, err := s.store.Compact(ctx, revision)
+	if err != nil {
+		switch err {
+		case kvstore.ErrCompacted:
+			http.Error(w, err.Error(), http.StatusGone)
+		case kvstore.ErrFutureRevision:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case raft.ErrNotLeader:
+			s.writeNotLeader(w, err)
+		default:
+			writeProposeError(w, err)
 		}
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]int{"index": index})
+}
+
+// HandleLeaseGrant создаёт аренду с TTL в секундах.
+func (s *HTTPServer) HandleLeaseGrant(w http.ResponseWriter, r *http.Request) {
+	ttl, err := strconv.Atoi(r.URL.Query().Get("ttl"))
+	if err != nil || ttl <= 0 {
+		http.Error(w, "Invalid ttl", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), kvstore.DefaultProposeTimeout)
+	defer cancel()
+
+	id, err := s.store.Grant(ctx, time.Duration(ttl)*time.Second)
+	if err != nil {
+		switch err {
+		case kvstore.ErrInvalidTTL:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case raft.ErrNotLeader:
+			s.writeNotLeader(w, err)
+		default:
+			writeProposeError(w, err)
+		}
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]int{"id": id, "ttl": ttl})
+}
+
+func (s *HTTPServer) HandleLeaseKeepAlive(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid lease id", http.StatusBadRequest)
+		return
+	}
+
+	ttl, err := s.store.KeepAlive(id)
+	if err != nil {
+		switch err {
+		case kvstore.ErrLeaseNotFound:
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case raft.ErrNotLeader:
+			s.writeNotLeader(w, err)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]int{"id": id, "ttl": int(ttl / time.Second)})
+}
+
+func (s *HTTPServer) HandleLeaseRevoke(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid lease id", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), kvstore.DefaultProposeTimeout)
+	defer cancel()
+
+	if err := s.store.Revoke(ctx, id); err != nil {
+		switch err {
+		case kvstore.ErrLeaseNotFound:
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case raft.ErrNotLeader:
+			s.writeNotLeader(w, err)
+		default:
+			writeProposeError(w, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *HTTPServer) HandleLeaseTimeToLive(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid lease id", http.StatusBadRequest)
+		return
+	}
+
+	lease, err := s.store.TimeToLive(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, lease)
+}
+
+// requestContext переносит ID клиента и номер запроса из заголовков
+// X-Client-ID и X-Request-Seq: повтор запроса после таймаута не будет
+// применён дважды.
+func requestContext(r *http.Request) (context.Context, error) {
+	clientID := r.Header.Get("X-Client-ID")
+	if clientID == "" {
+		return r.Context(), nil
+	}
+	seq, err := strconv.ParseUint(r.Header.Get("X-Request-Seq"), 10, 64)
+	if err != nil || seq == 0 {
+		return nil, errors.New("Invalid X-Request-Seq")
+	}
+	return kvstore.WithRequestID(r.Context(), clientID, seq), nil
+}
+
+// parseEventID разбирает id события SSE "<revision>.<n>".
+func parseEventID(id string) (revision, n int, ok bool) {
+	rev, seq, found := strings.Cut(id, ".")
+	if !found {
+		return 0, 0, false
+	}
+	revision, err := strconv.Atoi(rev)
+	if err != nil || revision <= 0 {
+		return 0, 0, false
+	}
+	n, err = strconv.Atoi(seq)
+	if err != nil || n < 0 {
+		return 0, 0, false
+	}
+	return revision, n, true
+}
+
+// parseRevision возвращает 0 для пустого параметра — текущее состояние.
+func parseRevision(rev string) (int, error) {
+	if rev == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(rev)
+	if err != nil || n < 0 {
+		return 0, errors.New("Invalid revision")
+	}
+	return n, nil
+}
+
+func writeProposeError(w http.ResponseWriter, err error) {
+	switch err {
+	case kvstore.ErrStaleRequest:
+		http.Error(w, err.Error(), http.StatusConflict)
+	case raft.ErrProposalTimeout:
+		http.Error(w, err.Error(), http.StatusGatewayTimeout)
+	case raft.ErrLeadershipLost, raft.ErrTransferInProgress:
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	default:
 		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// HandleAddMember принимает {"id": 4, "address": "host:port"} и отвечает,
+// когда новая конфигурация закоммичена.
+func (s *HTTPServer) HandleAddMember(w http.ResponseWriter, r *http.Request) {
+	var member raft.Member
+	if err := json.NewDecoder(r.Body).Decode(&member); err != nil ||
+		member.ID <= 0 || member.Address == "" {
+		http.Error(w, "Invalid member", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), kvstore.DefaultProposeTimeout)
+	defer cancel()
+
+	if _, err := s.raftNode.AddMember(member.ID, member.Address).Wait(ctx); err != nil {
+		s.writeMembershipError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, s.raftNode.Configuration())
+}
+
+func (s *HTTPServer) HandleRemoveMember(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid member id", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), kvstore.DefaultProposeTimeout)
+	defer cancel()
+
+	if _, err := s.raftNode.RemoveMember(id).Wait(ctx); err != nil {
+		s.writeMembershipError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, s.raftNode.Configuration())
+}
+
+func (s *HTTPServer) writeMembershipError(w http.ResponseWriter, err error) {
+	switch err {
+	case raft.ErrMemberExists:
+		http.Error(w, err.Error(), http.StatusConflict)
+	case raft.ErrMemberNotFound:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case raft.ErrConfigChangePending:
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	case raft.ErrNotLeader:
+		s.writeNotLeader(w, err)
+	default:
+		writeProposeError(w, err)
+	}
+}
+
+// HandleTransferLeader передаёт лидерство узлу to, а без параметра — самому
+// догнавшему голосующему участнику.
+func (s *HTTPServer) HandleTransferLeader(w http.ResponseWriter, r *http.Request) {
+	to := 0
+	if v := r.URL.Query().Get("to"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil || id <= 0 {
+			http.Error(w, "Invalid node id", http.StatusBadRequest)
+			return
+		}
+		to = id
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), kvstore.DefaultProposeTimeout)
+	defer cancel()
+
+	if err := s.raftNode.TransferLeadership(ctx, to); err != nil {
+		switch err {
+		case raft.ErrNotLeader:
+			s.writeNotLeader(w, err)
+		case raft.ErrMemberNotFound, raft.ErrNoTransferTarget:
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case raft.ErrTransferInProgress:
+			http.Error(w, err.Error(), http.StatusConflict)
+		case raft.ErrTransferTimeout:
+			http.Error(w, err.Error(), http.StatusGatewayTimeout)
+		default:
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		}
 		return
 	}
+	respondWithJSON(w, http.StatusOK, s.raftNode.GetClusterStatus())
+}
 
-	w.WriteHeader(http.StatusAccepted)
+// writeNotLeader отвечает 503 с подсказкой, где лидер. Raft знает только
+// gRPC-адреса участников, поэтому HTTP-запрос не перенаправляется: клиент
+// повторяет его на узле лидера сам.
+func (s *HTTPServer) writeNotLeader(w http.ResponseWriter, err error) {
+	msg := err.Error()
+	if leader := s.raftNode.GetLeader(); leader != "" {
+		w.Header().Set("X-Raft-Leader", leader)
+		msg = fmt.Sprintf("%s (leader: %s)", msg, leader)
+	}
+	w.Header().Set("Retry-After", "1")
+	http.Error(w, msg, http.StatusServiceUnavailable)
 }
 
 func (s *HTTPServer) HandleClusterStatus(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,426 @@
+package api
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"raft-kv-store/pkg/kvstore"
+	"raft-kv-store/pkg/raft"
+	pb "raft-kv-store/proto"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+type KeyValueServiceServer struct {
+	pb.UnimplementedKeyValueServiceServer
+	store    *kvstore.Store
+	raftNode *raft.RaftNode
+}
+
+func NewKeyValueService(store *kvstore.Store, raftNode *raft.RaftNode) *KeyValueServiceServer {
+	return &KeyValueServiceServer{
+		store:    store,
+		raftNode: raftNode,
+	}
+}
+
+func (s *KeyValueServiceServer) Get(ctx context.Context, req *pb.GetRequest) (*pb.GetResponse, error) {
+	opts, err := kvstore.ParseReadOptions(req.Consistency, req.MaxLag)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	opts.Revision = int(req.Revision)
+
+	ctx, cancel := context.WithTimeout(ctx, kvstore.DefaultProposeTimeout)
+	defer cancel()
+
+	kv, err := s.store.GetConsistent(ctx, req.Key, opts)
+	if err == kvstore.ErrKeyNotFound {
+		return &pb.GetResponse{Success: false}, nil
+	}
+	if err != nil {
+		return nil, s.toStatus(err)
+	}
+	return &pb.GetResponse{Value: kv.Value, Success: true, Version: uint64(kv.Version)}, nil
+}
+
+func (s *KeyValueServiceServer) Put(ctx context.Context, req *pb.PutRequest) (*pb.PutResponse, error) {
+	if req.Key == "" {
+		return nil, status.Error(codes.InvalidArgument, "key is required")
+	}
+
+	ctx, cancel := context.WithTimeout(withRequestID(ctx, req.ClientId, req.Seq), kvstore.DefaultProposeTimeout)
+	defer cancel()
+
+	var version int
+	var err error
+	switch {
+	case req.HasPrevValue:
+		version, err = s.store.CompareAndSwap(ctx, req.Key, req.PrevValue, req.Value, int(req.Lease))
+	case req.IfAbsent:
+		version, err = s.store.PutIfAbsent(ctx, req.Key, req.Value, int(req.Lease))
+	case req.Lease > 0:
+		version, err = s.store.PutWithLease(ctx, req.Key, req.Value, int(req.Lease))
+	default:
+		version, err = s.store.Propose(ctx, req.Key, req.Value)
+	}
+	if err != nil {
+		return nil, s.toStatus(err)
+	}
+	return &pb.PutResponse{Success: true, Version: uint64(version)}, nil
+}
+
+func (s *KeyValueServiceServer) Delete(ctx context.Context, req *pb.DeleteRequest) (*pb.DeleteResponse, error) {
+	ctx, cancel := context.WithTimeout(withRequestID(ctx, req.ClientId, req.Seq), kvstore.DefaultProposeTimeout)
+	defer cancel()
+
+	var err error
+	if req.Version > 0 {
+		_, err = s.store.DeleteIfVersion(ctx, req.Key, int(req.Version))
+	} else {
+		_, err = s.store.Delete(ctx, req.Key)
+	}
+	if err == kvstore.ErrKeyNotFound {
+		return &pb.DeleteResponse{Success: false}, nil
+	}
+	if err != nil {
+		return nil, s.toStatus(err)
+	}
+	return &pb.DeleteResponse{Success: true}, nil
+}
+
+func (s *KeyValueServiceServer) Txn(ctx context.Context, req *pb.TxnRequest) (*pb.TxnResponse, error) {
+	txn := kvstore.Txn{
+		Success: fromRequestOps(req.Success),
+		Failure: fromRequestOps(req.Failure),
+	}
+	for _, c := range req.Compare {
+		txn.Compares = append(txn.Compares, kvstore.Compare{
+			Key:     c.Key,
+			Target:  c.Target,
+			Result:  c.Result,
+			Value:   c.Value,
+			Version: int(c.Version),
+		})
+	}
+	if err := txn.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	ctx, cancel := context.WithTimeout(withRequestID(ctx, req.ClientId, req.Seq), kvstore.DefaultProposeTimeout)
+	defer cancel()
+
+	result, err := s.store.Txn(ctx, txn)
+	if err != nil {
+		return nil, s.toStatus(err)
+	}
+
+	resp := &pb.TxnResponse{Succeeded: result.Succeeded}
+	for _, r := range result.Results {
+		resp.Responses = append(resp.Responses, &pb.ResponseOp{
+			Op:      r.Op,
+			Key:     r.Key,
+			Value:   r.Value,
+			Version: uint64(r.Version),
+			Existed: r.Existed,
+		})
+	}
+	return resp, nil
+}
+
+func (s *KeyValueServiceServer) Scan(req *pb.ScanRequest, stream pb.KeyValueService_ScanServer) error {
+	opts, err := kvstore.ParseReadOptions(req.Consistency, req.MaxLag)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	opts.Revision = int(req.Revision)
+
+	start, end := kvstore.ScanBounds(req.Prefix, req.Start, req.End)
+	if req.Token != "" {
+		if start, err = kvstore.DecodeToken(req.Token); err != nil {
+			return status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(stream.Context(), kvstore.DefaultProposeTimeout)
+	page, err := s.store.RangeConsistent(ctx, start, end, pageLimit(req.Limit, 0), opts)
+	cancel()
+	if err != nil {
+		return s.toStatus(err)
+	}
+
+	sent := 0
+	for {
+		for _, kv := range page.Items {
+			resp := &pb.ScanResponse{
+				Key:      kv.Key,
+				Value:    kv.Value,
+				Version:  uint64(kv.Version),
+				Token:    kvstore.EncodeToken(kv.Key + "\x00"),
+				Revision: uint64(page.Revision),
+			}
+			if err := stream.Send(resp); err != nil {
+				return err
+			}
+			sent++
+		}
+
+		if page.Next == "" || (req.Limit > 0 && sent >= int(req.Limit)) {
+			return nil
+		}
+		next, _ := kvstore.DecodeToken(page.Next)
+		if page, err = s.store.RangeAt(next, end, pageLimit(req.Limit, sent), page.Revision); err != nil {
+			return s.toStatus(err)
+		}
+	}
+}
+
+func (s *KeyValueServiceServer) Compact(ctx context.Context, req *pb.CompactRequest) (*pb.CompactResponse, error) {
+	if req.Revision == 0 {
+		return nil, status.Error(codes.InvalidArgument, "revision is required")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, kvstore.DefaultProposeTimeout)
+	defer cancel()
+
+	index, err := s.store.Compact(ctx, int(req.Revision))
+	if err != nil {
+		return nil, s.toStatus(err)
+	}
+	return &pb.CompactResponse{Index: uint64(index)}, nil
+}
+
+// watchStartHeader — заголовок ответа Watch с ревизией, с которой идут события.
+const watchStartHeader = "watch-start-revision"
+
+// Watch отдаёт события до отмены стрима. События одной ревизии идут по
+// ключу, поэтому клиент возобновляет watch с ревизии последнего
+// полученного события и пропускает уже полученные.
+func (s *KeyValueServiceServer) Watch(req *pb.WatchRequest, stream pb.KeyValueService_WatchServer) error {
+	w, err := s.store.Watch(req.Key, req.Prefix, int(req.StartRevision))
+	if err != nil {
+		return s.toStatus(err)
+	}
+	defer w.Close()
+
+	// Клиент, подписавшийся с start_revision = 0, переподключается с этой
+	// ревизии, даже если не успел получить ни одного события
+	header := metadata.Pairs(watchStartHeader, strconv.Itoa(w.StartRevision()))
+	if err := stream.SendHeader(header); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		case ev, ok := <-w.Events():
+			if !ok {
+				return s.toStatus(w.Err())
+			}
+			resp := &pb.WatchResponse{
+				Type:     ev.Type,
+				Key:      ev.Key,
+				Value:    ev.Value,
+				Revision: uint64(ev.Revision),
+			}
+			if err := stream.Send(resp); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (s *KeyValueServiceServer) LeaseGrant(ctx context.Context, req *pb.LeaseGrantRequest) (*pb.LeaseGrantResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, kvstore.DefaultProposeTimeout)
+	defer cancel()
+
+	id, err := s.store.Grant(ctx, time.Duration(req.Ttl)*time.Second)
+	if err != nil {
+		return nil, s.toStatus(err)
+	}
+	return &pb.LeaseGrantResponse{Id: int64(id), Ttl: req.Ttl}, nil
+}
+
+func (s *KeyValueServiceServer) LeaseRevoke(ctx context.Context, req *pb.LeaseRevokeRequest) (*pb.LeaseRevokeResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, kvstore.DefaultProposeTimeout)
+	defer cancel()
+
+	if err := s.store.Revoke(ctx, int(req.Id)); err != nil {
+		return nil, s.toStatus(err)
+	}
+	return &pb.LeaseRevokeResponse{}, nil
+}
+
+func (s *KeyValueServiceServer) LeaseKeepAlive(ctx context.Context, req *pb.LeaseKeepAliveRequest) (*pb.LeaseKeepAliveResponse, error) {
+	ttl, err := s.store.KeepAlive(int(req.Id))
+	if err != nil {
+		return nil, s.toStatus(err)
+	}
+	return &pb.LeaseKeepAliveResponse{Id: req.Id, Ttl: int64(ttl / time.Second)}, nil
+}
+
+func (s *KeyValueServiceServer) LeaseTimeToLive(ctx context.Context, req *pb.LeaseTimeToLiveRequest) (*pb.LeaseTimeToLiveResponse, error) {
+	lease, err := s.store.TimeToLive(int(req.Id))
+	if err != nil {
+		return nil, s.toStatus(err)
+	}
+	return &pb.LeaseTimeToLiveResponse{
+		Id:        int64(lease.ID),
+		Ttl:       int64(lease.TTL),
+		Remaining: int64(lease.Remaining),
+		Keys:      lease.Keys,
+	}, nil
+}
+
+func withRequestID(ctx context.Context, clientID string, seq uint64) context.Context {
+	if clientID == "" {
+		return ctx
+	}
+	return kvstore.WithRequestID(ctx, clientID, seq)
+}
+
+func pageLimit(limit uint32, sent int) int {
+	if limit == 0 {
+		return kvstore.DefaultScanLimit
+	}
+	return min(int(limit)-sent, kvstore.DefaultScanLimit)
+}
+
+func fromRequestOps(ops []*pb.RequestOp) []kvstore.Command {
+	cmds := make([]kvstore.Command, 0, len(ops))
+	for _, op := range ops {
+		cmds = append(cmds, kvstore.Command{Op: op.Op, Key: op.Key, Value: op.Value, Lease: int(op.Lease)})
+	}
+	return cmds
+}
+
+// JoinCluster добавляет узел в конфигурацию и ждёт коммита записи.
+func (s *KeyValueServiceServer) JoinCluster(ctx context.Context, req *pb.JoinRequest) (*pb.JoinResponse, error) {
+	id, err := strconv.Atoi(req.NodeId)
+	if err != nil || id <= 0 || req.Address == "" {
+		return nil, status.Error(codes.InvalidArgument, "node_id and address are required")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, kvstore.DefaultProposeTimeout)
+	defer cancel()
+
+	if _, err := s.raftNode.AddMember(id, req.Address).Wait(ctx); err != nil {
+		return nil, s.toStatus(err)
+	}
+	return &pb.JoinResponse{Success: true}, nil
+}
+
+func (s *KeyValueServiceServer) RemoveNode(ctx context.Context, req *pb.RemoveNodeRequest) (*pb.RemoveNodeResponse, error) {
+	id, err := strconv.Atoi(req.NodeId)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid node_id")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, kvstore.DefaultProposeTimeout)
+	defer cancel()
+
+	if _, err := s.raftNode.RemoveMember(id).Wait(ctx); err != nil {
+		return nil, s.toStatus(err)
+	}
+	return &pb.RemoveNodeResponse{Success: true}, nil
+}
+
+func (s *KeyValueServiceServer) ClusterStatus(ctx context.Context, req *pb.StatusRequest) (*pb.StatusResponse, error) {
+	clusterStatus := s.raftNode.GetClusterStatus()
+
+	resp := &pb.StatusResponse{}
+	for _, node := range clusterStatus.Nodes {
+		nodeID := ""
+		if node.ID != 0 {
+			nodeID = strconv.Itoa(node.ID)
+		}
+		resp.Nodes = append(resp.Nodes, &pb.NodeStatus{
+			NodeId:   nodeID,
+			Address:  node.Address,
+			Role:     node.Role,
+			Term:     uint64(node.Term),
+			IsLeader: node.IsLeader,
+			Lag:      uint64(node.Lag),
+		})
+	}
+	return resp, nil
+}
+
+// ErrorDomain и причины ErrorInfo в деталях статуса. По причине клиент
+// различает ошибки с одинаковым кодом; у ReasonNotLeader и ReasonTooStale
+// в Metadata[LeaderMetadataKey] адрес лидера.
+const (
+	ErrorDomain       = "raft-kv-store"
+	LeaderMetadataKey = "leader"
+
+	ReasonNotLeader      = "NOT_LEADER"
+	ReasonTooStale       = "TOO_STALE"
+	ReasonConflict       = "CONFLICT"
+	ReasonCompacted      = "COMPACTED"
+	ReasonFutureRevision = "FUTURE_REVISION"
+	ReasonLeaseNotFound  = "LEASE_NOT_FOUND"
+	ReasonStaleRequest   = "STALE_REQUEST"
+	ReasonMemberExists   = "MEMBER_EXISTS"
+	ReasonMemberNotFound = "MEMBER_NOT_FOUND"
+)
+
+// toStatus переводит ошибки raft в gRPC-коды с ErrorInfo в деталях.
+func (s *KeyValueServiceServer) toStatus(err error) error {
+	switch err {
+	case raft.ErrNotLeader:
+		return withReason(codes.FailedPrecondition, err, ReasonNotLeader, s.leaderMetadata())
+	case raft.ErrTooStale:
+		return withReason(codes.FailedPrecondition, err, ReasonTooStale, s.leaderMetadata())
+	case raft.ErrLeadershipLost, raft.ErrReadIndexNotReady, raft.ErrNodeStopped, raft.ErrTransferInProgress:
+		return status.Error(codes.Unavailable, err.Error())
+	case kvstore.ErrConflict:
+		return withReason(codes.Aborted, err, ReasonConflict, nil)
+	case kvstore.ErrCompacted:
+		return withReason(codes.OutOfRange, err, ReasonCompacted, nil)
+	case kvstore.ErrFutureRevision:
+		return withReason(codes.OutOfRange, err, ReasonFutureRevision, nil)
+	case kvstore.ErrWatcherLagging:
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case kvstore.ErrWatchCancelled:
+		return status.Error(codes.Unavailable, err.Error())
+	case kvstore.ErrLeaseNotFound:
+		return withReason(codes.NotFound, err, ReasonLeaseNotFound, nil)
+	case kvstore.ErrStaleRequest:
+		return withReason(codes.AlreadyExists, err, ReasonStaleRequest, nil)
+	case kvstore.ErrInvalidTTL:
+		return status.Error(codes.InvalidArgument, err.Error())
+	case raft.ErrProposalTimeout, raft.ErrReadTimeout:
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	case raft.ErrConfigChangePending:
+		return status.Error(codes.Unavailable, err.Error())
+	case raft.ErrMemberExists:
+		return withReason(codes.AlreadyExists, err, ReasonMemberExists, nil)
+	case raft.ErrMemberNotFound:
+		return withReason(codes.NotFound, err, ReasonMemberNotFound, nil)
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
+
+func (s *KeyValueServiceServer) leaderMetadata() map[string]string {
+	return map[string]string{LeaderMetadataKey: s.raftNode.GetLeader()}
+}
+
+// withReason добавляет к статусу ErrorInfo с причиной ошибки.
+func withReason(code codes.Code, err error, reason string, md map[string]string) error {
+	st := status.New(code, err.Error())
+	detailed, derr := st.WithDetails(&errdetails.ErrorInfo{
+		Reason:   reason,
+		Domain:   ErrorDomain,
+		Metadata: md,
+	})
+	if derr != nil {
+		return st.Err()
+	}
+	return detailed.Err()
+}
@@ -0,0 +1,297 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"raft-kv-store/pkg/kvstore"
+	"raft-kv-store/pkg/raft"
+	"raft-kv-store/pkg/recipes"
+	pb "raft-kv-store/proto"
+
+	"github.com/gorilla/mux"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Эндпоинты рецептов работают с арендой клиента: lease — существующая
+// аренда, которую клиент продлевает сам через /lease/{id}/keepalive, либо
+// ttl — аренда создаётся на время запроса и возвращается в ответе.
+
+// recipeWaitTimeout ограничивает ожидание очереди в HTTP-запросе: ответ
+// должен уйти раньше WriteTimeout сервера. Не дождавшийся клиент получает
+// 408 и повторяет запрос.
+const recipeWaitTimeout = 8 * time.Second
+
+type lockResponse struct {
+	Key   string `json:"key"`
+	Token int    `json:"token"`
+	Lease int    `json:"lease"`
+}
+
+func (s *HTTPServer) HandleLock(w http.ResponseWriter, r *http.Request) {
+	session, ok := s.recipeSession(w, r)
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), recipeWaitTimeout)
+	defer cancel()
+
+	m := recipes.NewMutex(session, mux.Vars(r)["name"])
+	if err := m.Lock(ctx); err != nil {
+		s.abandonRecipeSession(r, session)
+		s.writeRecipeError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, lockResponse{Key: m.Key(), Token: m.Token(), Lease: session.Lease()})
+}
+
+func (s *HTTPServer) HandleUnlock(w http.ResponseWriter, r *http.Request) {
+	s.releaseRecipeKey(w, r, recipes.LockPrefix(mux.Vars(r)["name"]))
+}
+
+func (s *HTTPServer) HandleSemaphoreAcquire(w http.ResponseWriter, r *http.Request) {
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 {
+		http.Error(w, "Invalid limit", http.StatusBadRequest)
+		return
+	}
+	session, ok := s.recipeSession(w, r)
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), recipeWaitTimeout)
+	defer cancel()
+
+	sem := recipes.NewSemaphore(session, mux.Vars(r)["name"], limit)
+	if err := sem.Acquire(ctx); err != nil {
+		s.abandonRecipeSession(r, session)
+		s.writeRecipeError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, lockResponse{Key: sem.Key(), Token: sem.Token(), Lease: session.Lease()})
+}
+
+func (s *HTTPServer) HandleSemaphoreRelease(w http.ResponseWriter, r *http.Request) {
+	s.releaseRecipeKey(w, r, recipes.SemaphorePrefix(mux.Vars(r)["name"]))
+}
+
+func (s *HTTPServer) HandleCampaign(w http.ResponseWriter, r *http.Request) {
+	session, ok := s.recipeSession(w, r)
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), recipeWaitTimeout)
+	defer cancel()
+
+	e := recipes.NewElection(session, mux.Vars(r)["name"])
+	if err := e.Campaign(ctx, r.URL.Query().Get("value")); err != nil {
+		s.abandonRecipeSession(r, session)
+		s.writeRecipeError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, lockResponse{Key: e.Key(), Token: e.Token(), Lease: session.Lease()})
+}
+
+func (s *HTTPServer) HandleResign(w http.ResponseWriter, r *http.Request) {
+	s.releaseRecipeKey(w, r, recipes.ElectionPrefix(mux.Vars(r)["name"]))
+}
+
+func (s *HTTPServer) HandleElectionLeader(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), kvstore.DefaultProposeTimeout)
+	defer cancel()
+
+	leader, err := recipes.ObserveElection(s.store, mux.Vars(r)["name"]).Leader(ctx)
+	if err != nil {
+		s.writeRecipeError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, leader)
+}
+
+func (s *HTTPServer) recipeSession(w http.ResponseWriter, r *http.Request) (*recipes.Session, bool) {
+	query := r.URL.Query()
+	lease, _ := strconv.Atoi(query.Get("lease"))
+	ttl, _ := strconv.Atoi(query.Get("ttl"))
+
+	session, err := newRecipeSession(r.Context(), s.store, lease, ttl)
+	if err != nil {
+		s.writeRecipeError(w, err)
+		return nil, false
+	}
+	return session, true
+}
+
+// abandonRecipeSession отзывает аренду, созданную по ttl для неудачного
+// запроса: клиент не узнал её id, и ключи не должны ждать истечения TTL.
+// Ключ очереди рецепт уже удалил сам.
+func (s *HTTPServer) abandonRecipeSession(r *http.Request, session *recipes.Session) {
+	if lease, _ := strconv.Atoi(r.URL.Query().Get("lease")); lease > 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), kvstore.DefaultProposeTimeout)
+	defer cancel()
+	session.Close(ctx)
+}
+
+func (s *HTTPServer) releaseRecipeKey(w http.ResponseWriter, r *http.Request, prefix string) {
+	key := r.URL.Query().Get("key")
+	if !strings.HasPrefix(key, prefix) {
+		http.Error(w, "Key does not belong to this recipe", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), kvstore.DefaultProposeTimeout)
+	defer cancel()
+
+	if _, err := s.store.Delete(ctx, key); err != nil {
+		s.writeRecipeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func newRecipeSession(ctx context.Context, store *kvstore.Store, lease, ttl int) (*recipes.Session, error) {
+	if lease > 0 {
+		return recipes.SessionFromLease(store, lease), nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, kvstore.DefaultProposeTimeout)
+	defer cancel()
+
+	id, err := store.Grant(ctx, time.Duration(ttl)*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	return recipes.SessionFromLease(store, id), nil
+}
+
+func (s *HTTPServer) writeRecipeError(w http.ResponseWriter, err error) {
+	switch err {
+	case kvstore.ErrKeyNotFound, kvstore.ErrLeaseNotFound, recipes.ErrNoLeader, recipes.ErrSessionExpired:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case kvstore.ErrInvalidTTL:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case raft.ErrNotLeader:
+		s.writeNotLeader(w, err)
+	case context.Canceled, context.DeadlineExceeded:
+		http.Error(w, err.Error(), http.StatusRequestTimeout)
+	default:
+		writeProposeError(w, err)
+	}
+}
+
+type ConcurrencyServiceServer struct {
+	pb.UnimplementedConcurrencyServiceServer
+	store    *kvstore.Store
+	raftNode *raft.RaftNode
+	kv       *KeyValueServiceServer
+}
+
+func NewConcurrencyService(store *kvstore.Store, raftNode *raft.RaftNode) *ConcurrencyServiceServer {
+	return &ConcurrencyServiceServer{
+		store:    store,
+		raftNode: raftNode,
+		kv:       NewKeyValueService(store, raftNode),
+	}
+}
+
+func (s *ConcurrencyServiceServer) Lock(ctx context.Context, req *pb.LockRequest) (*pb.LockResponse, error) {
+	session, err := newRecipeSession(ctx, s.store, int(req.Lease), int(req.Ttl))
+	if err != nil {
+		return nil, s.kv.toStatus(err)
+	}
+
+	m := recipes.NewMutex(session, req.Name)
+	if err := m.Lock(ctx); err != nil {
+		return nil, s.toStatus(err)
+	}
+	return &pb.LockResponse{Key: m.Key(), Token: uint64(m.Token()), Lease: int64(session.Lease())}, nil
+}
+
+func (s *ConcurrencyServiceServer) Unlock(ctx context.Context, req *pb.ReleaseRequest) (*pb.ReleaseResponse, error) {
+	return s.release(ctx, req, recipes.LockPrefix(req.Name))
+}
+
+func (s *ConcurrencyServiceServer) SemaphoreAcquire(ctx context.Context, req *pb.SemaphoreRequest) (*pb.LockResponse, error) {
+	if req.Limit == 0 {
+		return nil, status.Error(codes.InvalidArgument, "limit is required")
+	}
+	session, err := newRecipeSession(ctx, s.store, int(req.Lease), int(req.Ttl))
+	if err != nil {
+		return nil, s.kv.toStatus(err)
+	}
+
+	sem := recipes.NewSemaphore(session, req.Name, int(req.Limit))
+	if err := sem.Acquire(ctx); err != nil {
+		return nil, s.toStatus(err)
+	}
+	return &pb.LockResponse{Key: sem.Key(), Token: uint64(sem.Token()), Lease: int64(session.Lease())}, nil
+}
+
+func (s *ConcurrencyServiceServer) SemaphoreRelease(ctx context.Context, req *pb.ReleaseRequest) (*pb.ReleaseResponse, error) {
+	return s.release(ctx, req, recipes.SemaphorePrefix(req.Name))
+}
+
+func (s *ConcurrencyServiceServer) Campaign(ctx context.Context, req *pb.CampaignRequest) (*pb.LockResponse, error) {
+	session, err := newRecipeSession(ctx, s.store, int(req.Lease), int(req.Ttl))
+	if err != nil {
+		return nil, s.kv.toStatus(err)
+	}
+
+	e := recipes.NewElection(session, req.Name)
+	if err := e.Campaign(ctx, req.Value); err != nil {
+		return nil, s.toStatus(err)
+	}
+	return &pb.LockResponse{Key: e.Key(), Token: uint64(e.Token()), Lease: int64(session.Lease())}, nil
+}
+
+func (s *ConcurrencyServiceServer) Resign(ctx context.Context, req *pb.ReleaseRequest) (*pb.ReleaseResponse, error) {
+	return s.release(ctx, req, recipes.ElectionPrefix(req.Name))
+}
+
+func (s *ConcurrencyServiceServer) Leader(ctx context.Context, req *pb.LeaderRequest) (*pb.LeaderResponse, error) {
+	leader, err := recipes.ObserveElection(s.store, req.Name).Leader(ctx)
+	if err != nil {
+		return nil, s.toStatus(err)
+	}
+	return &pb.LeaderResponse{Key: leader.Key, Value: leader.Value, Token: uint64(leader.Version)}, nil
+}
+
+func (s *ConcurrencyServiceServer) Observe(req *pb.LeaderRequest, stream pb.ConcurrencyService_ObserveServer) error {
+	for leader := range recipes.ObserveElection(s.store, req.Name).Observe(stream.Context()) {
+		resp := &pb.LeaderResponse{Key: leader.Key, Value: leader.Value, Token: uint64(leader.Version)}
+		if err := stream.Send(resp); err != nil {
+			return err
+		}
+	}
+	return stream.Context().Err()
+}
+
+func (s *ConcurrencyServiceServer) release(ctx context.Context, req *pb.ReleaseRequest, prefix string) (*pb.ReleaseResponse, error) {
+	if !strings.HasPrefix(req.Key, prefix) {
+		return nil, status.Error(codes.InvalidArgument, "key does not belong to this recipe")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, kvstore.DefaultProposeTimeout)
+	defer cancel()
+
+	if _, err := s.store.Delete(ctx, req.Key); err != nil {
+		return nil, s.toStatus(err)
+	}
+	return &pb.ReleaseResponse{}, nil
+}
+
+func (s *ConcurrencyServiceServer) toStatus(err error) error {
+	switch err {
+	case recipes.ErrNoLeader, kvstore.ErrKeyNotFound, recipes.ErrSessionExpired:
+		return status.Error(codes.NotFound, err.Error())
+	case context.Canceled:
+		return status.Error(codes.Canceled, err.Error())
+	case context.DeadlineExceeded:
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	}
+	return s.kv.toStatus(err)
+}
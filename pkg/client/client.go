@@ -0,0 +1,401 @@
+package client
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"math"
+	mathrand "math/rand"
+	"sync"
+	"time"
+
+	"raft-kv-store/pkg/api"
+	"raft-kv-store/pkg/kvstore"
+	"raft-kv-store/pkg/raft"
+	pb "raft-kv-store/proto"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	DefaultMaxRetries  = 10
+	DefaultBackoffBase = 50 * time.Millisecond
+	DefaultBackoffMax  = 2 * time.Second
+)
+
+var (
+	ErrNoEndpoints = errors.New("client: no endpoints configured")
+	ErrNoLeader    = errors.New("client: no leader found")
+)
+
+// Config — параметры клиента. Нулевые значения заменяются значениями
+// по умолчанию.
+type Config struct {
+	// Endpoints — gRPC-адреса узлов кластера.
+	Endpoints []string
+	// ClientID идентифицирует сессию для дедупликации повторов записи.
+	// Пустой — генерируется случайный.
+	ClientID string
+	// ReadConsistency — уровень согласованности Get: "", "linearizable",
+	// "lease" или "stale".
+	ReadConsistency string
+
+	MaxRetries  int
+	BackoffBase time.Duration
+	BackoffMax  time.Duration
+}
+
+// Client отправляет запросы лидеру KeyValueService. Лидер находится через
+// ClusterStatus и кэшируется; при ErrNotLeader или обрыве соединения
+// клиент повторяет запрос с экспоненциальной задержкой. Записи помечаются
+// ClientID и номером запроса, поэтому повтор не применяется дважды.
+type Client struct {
+	cfg Config
+
+	// Сервер помнит только последний Seq клиента и отклоняет меньшие,
+	// поэтому записи идут по одной под writeMu
+	writeMu sync.Mutex
+	seq     uint64
+
+	mu     sync.Mutex
+	conns  map[string]*grpc.ClientConn
+	leader string
+}
+
+func New(cfg Config) (*Client, error) {
+	if len(cfg.Endpoints) == 0 {
+		return nil, ErrNoEndpoints
+	}
+	if cfg.ClientID == "" {
+		b := make([]byte, 16)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		cfg.ClientID = hex.EncodeToString(b)
+	}
+	if cfg.MaxRetries == 0 {
+		cfg.MaxRetries = DefaultMaxRetries
+	}
+	if cfg.BackoffBase == 0 {
+		cfg.BackoffBase = DefaultBackoffBase
+	}
+	if cfg.BackoffMax == 0 {
+		cfg.BackoffMax = DefaultBackoffMax
+	}
+
+	return &Client{
+		cfg:   cfg,
+		conns: make(map[string]*grpc.ClientConn),
+	}, nil
+}
+
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var firstErr error
+	for addr, conn := range c.conns {
+		if err := conn.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		delete(c.conns, addr)
+	}
+	c.leader = ""
+	return firstErr
+}
+
+func (c *Client) Get(ctx context.Context, key string) (kvstore.KeyValue, error) {
+	var kv kvstore.KeyValue
+	err := c.withLeader(ctx, func(ctx context.Context, kvc pb.KeyValueServiceClient) error {
+		resp, err := kvc.Get(ctx, &pb.GetRequest{Key: key, Consistency: c.cfg.ReadConsistency})
+		if err != nil {
+			return err
+		}
+		if !resp.Success {
+			return kvstore.ErrKeyNotFound
+		}
+		kv = kvstore.KeyValue{Key: key, Value: resp.Value, Version: int(resp.Version)}
+		return nil
+	})
+	return kv, err
+}
+
+// Put возвращает версию ключа — raft-индекс записи.
+func (c *Client) Put(ctx context.Context, key, value string) (int, error) {
+	var version int
+	err := c.write(ctx, func(ctx context.Context, kvc pb.KeyValueServiceClient, seq uint64) error {
+		resp, err := kvc.Put(ctx, &pb.PutRequest{
+			Key:      key,
+			Value:    value,
+			ClientId: c.cfg.ClientID,
+			Seq:      seq,
+		})
+		if err != nil {
+			return err
+		}
+		version = int(resp.Version)
+		return nil
+	})
+	return version, err
+}
+
+// Delete возвращает kvstore.ErrKeyNotFound, если ключа не было.
+func (c *Client) Delete(ctx context.Context, key string) error {
+	return c.write(ctx, func(ctx context.Context, kvc pb.KeyValueServiceClient, seq uint64) error {
+		resp, err := kvc.Delete(ctx, &pb.DeleteRequest{
+			Key:      key,
+			ClientId: c.cfg.ClientID,
+			Seq:      seq,
+		})
+		if err != nil {
+			return err
+		}
+		if !resp.Success {
+			return kvstore.ErrKeyNotFound
+		}
+		return nil
+	})
+}
+
+func (c *Client) Txn(ctx context.Context, txn kvstore.Txn) (kvstore.TxnResult, error) {
+	if err := txn.Validate(); err != nil {
+		return kvstore.TxnResult{}, err
+	}
+	req := &pb.TxnRequest{
+		Success:  toRequestOps(txn.Success),
+		Failure:  toRequestOps(txn.Failure),
+		ClientId: c.cfg.ClientID,
+	}
+	for _, cmp := range txn.Compares {
+		req.Compare = append(req.Compare, &pb.Compare{
+			Key:     cmp.Key,
+			Target:  cmp.Target,
+			Result:  cmp.Result,
+			Value:   cmp.Value,
+			Version: uint64(cmp.Version),
+		})
+	}
+
+	var result kvstore.TxnResult
+	err := c.write(ctx, func(ctx context.Context, kvc pb.KeyValueServiceClient, seq uint64) error {
+		req.Seq = seq
+		resp, err := kvc.Txn(ctx, req)
+		if err != nil {
+			return err
+		}
+		result = kvstore.TxnResult{Succeeded: resp.Succeeded}
+		for _, r := range resp.Responses {
+			result.Results = append(result.Results, kvstore.OpResult{
+				Op:      r.Op,
+				Key:     r.Key,
+				Value:   r.Value,
+				Version: int(r.Version),
+				Existed: r.Existed,
+			})
+		}
+		return nil
+	})
+	return result, err
+}
+
+// write выполняет запись на лидере со следующим номером запроса. Повторы
+// внутри withLeader идут с тем же номером.
+func (c *Client) write(ctx context.Context, fn func(context.Context, pb.KeyValueServiceClient, uint64) error) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	c.seq++
+	seq := c.seq
+	return c.withLeader(ctx, func(ctx context.Context, kvc pb.KeyValueServiceClient) error {
+		return fn(ctx, kvc, seq)
+	})
+}
+
+// withLeader выполняет fn на лидере, повторяя при смене лидера и
+// недоступности узлов.
+func (c *Client) withLeader(ctx context.Context, fn func(context.Context, pb.KeyValueServiceClient) error) error {
+	var err error
+	for attempt := 0; attempt <= c.cfg.MaxRetries; attempt++ {
+		if attempt > 0 {
+			if serr := sleep(ctx, c.backoff(attempt)); serr != nil {
+				return serr
+			}
+		}
+
+		var kvc pb.KeyValueServiceClient
+		kvc, err = c.leaderClient(ctx)
+		if err != nil {
+			continue
+		}
+
+		err = fn(ctx, kvc)
+		if err == nil || !retryable(err) {
+			return fromStatus(err)
+		}
+		c.resetLeader(leaderHint(err))
+	}
+	return fromStatus(err)
+}
+
+func (c *Client) leaderClient(ctx context.Context) (pb.KeyValueServiceClient, error) {
+	c.mu.Lock()
+	leader := c.leader
+	c.mu.Unlock()
+
+	if leader == "" {
+		var err error
+		if leader, err = c.discoverLeader(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	conn, err := c.conn(leader)
+	if err != nil {
+		return nil, err
+	}
+	return pb.NewKeyValueServiceClient(conn), nil
+}
+
+// discoverLeader опрашивает endpoints по очереди и кэширует первого
+// названного лидера.
+func (c *Client) discoverLeader(ctx context.Context) (string, error) {
+	for _, endpoint := range c.cfg.Endpoints {
+		conn, err := c.conn(endpoint)
+		if err != nil {
+			continue
+		}
+
+		reqCtx, cancel := context.WithTimeout(ctx, time.Second)
+		resp, err := pb.NewKeyValueServiceClient(conn).ClusterStatus(reqCtx, &pb.StatusRequest{})
+		cancel()
+		if err != nil {
+			continue
+		}
+
+		for _, node := range resp.Nodes {
+			if node.IsLeader && node.Address != "" {
+				c.mu.Lock()
+				c.leader = node.Address
+				c.mu.Unlock()
+				return node.Address, nil
+			}
+		}
+	}
+	return "", ErrNoLeader
+}
+
+func (c *Client) resetLeader(hint string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.leader = hint
+}
+
+func (c *Client) conn(addr string) (*grpc.ClientConn, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if conn, ok := c.conns[addr]; ok {
+		return conn, nil
+	}
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, err
+	}
+	c.conns[addr] = conn
+	return conn, nil
+}
+
+// backoff — экспоненциальная задержка с джиттером до BackoffMax.
+func (c *Client) backoff(attempt int) time.Duration {
+	d := float64(c.cfg.BackoffBase) * math.Pow(2, float64(attempt-1))
+	if d > float64(c.cfg.BackoffMax) {
+		d = float64(c.cfg.BackoffMax)
+	}
+	return time.Duration(d/2 + mathrand.Float64()*d/2)
+}
+
+// retryable — ошибка лидерства (FailedPrecondition) или недоступности узла.
+func retryable(err error) bool {
+	switch status.Code(err) {
+	case codes.FailedPrecondition, codes.Unavailable:
+		return true
+	}
+	return false
+}
+
+// reasonErrors сопоставляет причины ErrorInfo сервера ошибкам пакетов
+// kvstore и raft.
+var reasonErrors = map[string]error{
+	api.ReasonNotLeader:      raft.ErrNotLeader,
+	api.ReasonTooStale:       raft.ErrTooStale,
+	api.ReasonConflict:       kvstore.ErrConflict,
+	api.ReasonCompacted:      kvstore.ErrCompacted,
+	api.ReasonFutureRevision: kvstore.ErrFutureRevision,
+	api.ReasonLeaseNotFound:  kvstore.ErrLeaseNotFound,
+	api.ReasonStaleRequest:   kvstore.ErrStaleRequest,
+	api.ReasonMemberExists:   raft.ErrMemberExists,
+	api.ReasonMemberNotFound: raft.ErrMemberNotFound,
+}
+
+// errorInfo достаёт ErrorInfo сервера из деталей статуса.
+func errorInfo(err error) *errdetails.ErrorInfo {
+	for _, detail := range status.Convert(err).Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok && info.Domain == api.ErrorDomain {
+			return info
+		}
+	}
+	return nil
+}
+
+// leaderHint достаёт адрес лидера из деталей ErrNotLeader и ErrTooStale.
+func leaderHint(err error) string {
+	if status.Code(err) != codes.FailedPrecondition {
+		return ""
+	}
+	if info := errorInfo(err); info != nil {
+		return info.Metadata[api.LeaderMetadataKey]
+	}
+	return ""
+}
+
+// fromStatus переводит статус обратно в ошибку kvstore или raft по
+// причине из деталей, если она известна.
+func fromStatus(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); !ok {
+		return err
+	}
+	if info := errorInfo(err); info != nil {
+		if known, ok := reasonErrors[info.Reason]; ok {
+			return known
+		}
+	}
+	return err
+}
+
+func toRequestOps(ops []kvstore.Command) []*pb.RequestOp {
+	out := make([]*pb.RequestOp, 0, len(ops))
+	for _, op := range ops {
+		out = append(out, &pb.RequestOp{Op: op.Op, Key: op.Key, Value: op.Value, Lease: int64(op.Lease)})
+	}
+	return out
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
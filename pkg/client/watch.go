@@ -0,0 +1,151 @@
+package client
+
+import (
+	"context"
+	"io"
+	"strconv"
+	"sync"
+
+	"raft-kv-store/pkg/kvstore"
+	pb "raft-kv-store/proto"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Watcher — подписка на изменения. При обрыве стрима клиент
+// переподключается к любому endpoint и продолжает с последней полученной
+// ревизии, пропуская уже полученные события этой ревизии: сервер отдаёт их
+// в одном порядке, так что события не теряются и не повторяются. Подписка с
+// fromRevision = 0 закрепляет начальную ревизию из заголовка первого ответа.
+type Watcher struct {
+	events chan kvstore.Event
+	cancel context.CancelFunc
+
+	// delivered — число отданных событий ревизии req.StartRevision
+	delivered int
+
+	mu  sync.Mutex
+	err error
+}
+
+func (w *Watcher) Events() <-chan kvstore.Event {
+	return w.events
+}
+
+// Err возвращает причину закрытия Events: kvstore.ErrCompacted, если
+// нужная ревизия уже удалена, или ошибку контекста.
+func (w *Watcher) Err() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err
+}
+
+func (w *Watcher) Close() {
+	w.cancel()
+}
+
+// Watch подписывается на ключ (или префикс при prefix=true). fromRevision = 0 —
+// только новые события.
+func (c *Client) Watch(ctx context.Context, key string, prefix bool, fromRevision int) *Watcher {
+	ctx, cancel := context.WithCancel(ctx)
+	w := &Watcher{
+		events: make(chan kvstore.Event),
+		cancel: cancel,
+	}
+	go c.runWatch(ctx, w, &pb.WatchRequest{
+		Key:           key,
+		Prefix:        prefix,
+		StartRevision: uint64(fromRevision),
+	})
+	return w
+}
+
+func (c *Client) runWatch(ctx context.Context, w *Watcher, req *pb.WatchRequest) {
+	defer close(w.events)
+
+	for attempt := 0; ; attempt++ {
+		endpoint := c.cfg.Endpoints[attempt%len(c.cfg.Endpoints)]
+		err := c.watchOnce(ctx, endpoint, w, req)
+		if ctx.Err() != nil {
+			w.setErr(ctx.Err())
+			return
+		}
+		if status.Code(err) == codes.OutOfRange {
+			w.setErr(fromStatus(err))
+			return
+		}
+		if err := sleep(ctx, c.backoff(min(attempt+1, 16))); err != nil {
+			w.setErr(err)
+			return
+		}
+	}
+}
+
+// watchStartHeader совпадает с заголовком, который отправляет сервер.
+const watchStartHeader = "watch-start-revision"
+
+// watchOnce читает один стрим, сдвигая req.StartRevision за каждым событием.
+// Первые w.delivered событий ревизии req.StartRevision уже отданы и
+// пропускаются.
+func (c *Client) watchOnce(ctx context.Context, endpoint string, w *Watcher, req *pb.WatchRequest) error {
+	conn, err := c.conn(endpoint)
+	if err != nil {
+		return err
+	}
+	stream, err := pb.NewKeyValueServiceClient(conn).Watch(ctx, req)
+	if err != nil {
+		return err
+	}
+	header, err := stream.Header()
+	if err != nil {
+		return err
+	}
+	if values := header.Get(watchStartHeader); req.StartRevision == 0 && len(values) > 0 {
+		start, err := strconv.ParseUint(values[0], 10, 64)
+		if err != nil {
+			return err
+		}
+		req.StartRevision = start
+	}
+
+	skip := w.delivered
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if resp.Revision == req.StartRevision && skip > 0 {
+			skip--
+			continue
+		}
+
+		ev := kvstore.Event{
+			Type:     resp.Type,
+			Key:      resp.Key,
+			Value:    resp.Value,
+			Revision: int(resp.Revision),
+		}
+		select {
+		case w.events <- ev:
+			if resp.Revision == req.StartRevision {
+				w.delivered++
+			} else {
+				req.StartRevision = resp.Revision
+				w.delivered = 1
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (w *Watcher) setErr(err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.err = err
+}
@@ -0,0 +1,59 @@
+package kvstore
+
+import (
+	"errors"
+	"time"
+)
+
+const (
+	OpSet             = "SET"
+	OpDelete          = "DELETE"
+	OpCompareAndSwap  = "CAS"
+	OpPutIfAbsent     = "PUT_IF_ABSENT"
+	OpDeleteIfVersion = "DELETE_IF_VERSION"
+)
+
+var (
+	ErrKeyNotFound = errors.New("kvstore: key not found")
+	ErrConflict    = errors.New("kvstore: condition does not hold")
+)
+
+// Command — запись лога для kvstore. PrevValue используется только CAS,
+// Version — только DELETE_IF_VERSION и COMPACT, TTL — только LEASE_GRANT,
+// Compares/Success/Failure — только TXN. Lease привязывает записываемый
+// ключ к аренде. ClientID и Seq задают сессию клиента для дедупликации
+// повторов, Timestamp — время лидера в момент предложения.
+type Command struct {
+	Op        string        `json:"op"`
+	Key       string        `json:"key"`
+	Value     string        `json:"value,omitempty"`
+	Lease     int           `json:"lease,omitempty"`
+	PrevValue string        `json:"-"`
+	Version   int           `json:"-"`
+	TTL       time.Duration `json:"-"`
+
+	ClientID  string `json:"-"`
+	Seq       uint64 `json:"-"`
+	Timestamp int64  `json:"-"`
+
+	Compares []Compare `json:"-"`
+	Success  []Command `json:"-"`
+	Failure  []Command `json:"-"`
+}
+
+// ApplyResult — результат применения команды, возвращаемый через ApplyFuture.
+// Version — версия ключа после команды (для удаления — ревизия удаления)
+// либо текущая версия при конфликте.
+type ApplyResult struct {
+	Existed   bool
+	Succeeded bool
+	Version   int
+}
+
+// KeyValue — значение ключа и raft-индекс его последней записи.
+type KeyValue struct {
+	Key     string `json:"key"`
+	Value   string `json:"value"`
+	Version int    `json:"version"`
+	Lease   int    `json:"lease,omitempty"`
+}
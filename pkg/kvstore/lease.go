@@ -0,0 +1,232 @@
+package kvstore
+
+import (
+	"context"
+	"errors"
+	"log"
+	"raft-kv-store/pkg/raft"
+	"sort"
+	"time"
+)
+
+const (
+	OpLeaseGrant  = "LEASE_GRANT"
+	OpLeaseRevoke = "LEASE_REVOKE"
+
+	MinLeaseTTL        = time.Second
+	leaseCheckInterval = 500 * time.Millisecond
+)
+
+var (
+	ErrLeaseNotFound = errors.New("kvstore: lease not found")
+	ErrInvalidTTL    = errors.New("kvstore: lease TTL is too short")
+)
+
+// Lease — аренда с привязанными ключами. TTL и Remaining в секундах.
+type Lease struct {
+	ID        int      `json:"id"`
+	TTL       int      `json:"ttl"`
+	Remaining int      `json:"remaining"`
+	Keys      []string `json:"keys"`
+}
+
+// lease: id, ttl и keys реплицируются через лог, deadline — локальное
+// время истечения, по которому решает только лидер.
+type lease struct {
+	id       int
+	ttl      time.Duration
+	keys     map[string]struct{}
+	deadline time.Time
+	revoking bool
+}
+
+type leaseSnapshot struct {
+	ID  int
+	TTL time.Duration
+}
+
+// Grant создаёт аренду. Её ID — raft-индекс записи LEASE_GRANT; его
+// возвращает результат применения, поэтому повтор запроса получает ID
+// аренды, созданной первой попыткой, а не индекс своей записи.
+func (s *Store) Grant(ctx context.Context, ttl time.Duration) (int, error) {
+	if ttl < MinLeaseTTL {
+		return 0, ErrInvalidTTL
+	}
+	_, result, err := s.propose(ctx, Command{Op: OpLeaseGrant, TTL: ttl})
+	if err != nil {
+		return 0, err
+	}
+	if err, ok := result.(error); ok {
+		return 0, err
+	}
+	id, _ := result.(int)
+	return id, nil
+}
+
+// Revoke удаляет аренду вместе со всеми привязанными ключами.
+func (s *Store) Revoke(ctx context.Context, id int) error {
+	_, result, err := s.propose(ctx, Command{Op: OpLeaseRevoke, Lease: id})
+	if err != nil {
+		return err
+	}
+	if err, ok := result.(error); ok {
+		return err
+	}
+	return nil
+}
+
+// KeepAlive продлевает аренду на полный TTL. Сроки аренд отслеживает
+// только лидер, поэтому на остальных узлах возвращается ErrNotLeader.
+func (s *Store) KeepAlive(id int) (time.Duration, error) {
+	if !s.raft.IsLeader() {
+		return 0, raft.ErrNotLeader
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	l, ok := s.leases[id]
+	if !ok {
+		return 0, ErrLeaseNotFound
+	}
+	l.deadline = time.Now().Add(l.ttl)
+	return l.ttl, nil
+}
+
+// TimeToLive возвращает аренду с оставшимся временем. Remaining точен
+// только на лидере.
+func (s *Store) TimeToLive(id int) (Lease, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	l, ok := s.leases[id]
+	if !ok {
+		return Lease{}, ErrLeaseNotFound
+	}
+
+	info := Lease{
+		ID:        l.id,
+		TTL:       int(l.ttl / time.Second),
+		Remaining: int(max(time.Until(l.deadline), 0) / time.Second),
+		Keys:      make([]string, 0, len(l.keys)),
+	}
+	for key := range l.keys {
+		info.Keys = append(info.Keys, key)
+	}
+	sort.Strings(info.Keys)
+	return info, nil
+}
+
+func (s *Store) applyLeaseGrantLocked(index int, ttl time.Duration) int {
+	s.leases[index] = &lease{
+		id:       index,
+		ttl:      ttl,
+		keys:     make(map[string]struct{}),
+		deadline: time.Now().Add(ttl),
+	}
+	return index
+}
+
+func (s *Store) applyLeaseRevokeLocked(index, id int) interface{} {
+	l, ok := s.leases[id]
+	if !ok {
+		return ErrLeaseNotFound
+	}
+
+	// Порядок удаления определяет порядок событий watch — он должен
+	// совпадать на всех узлах
+	keys := make([]string, 0, len(l.keys))
+	for key := range l.keys {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		s.deleteLocked(key, index)
+	}
+	delete(s.leases, id)
+	return nil
+}
+
+// attachLocked переносит ключ на аренду leaseID (0 — без аренды).
+func (s *Store) attachLocked(key string, prevLease, leaseID int) {
+	if prev, ok := s.leases[prevLease]; ok {
+		delete(prev.keys, key)
+	}
+	if l, ok := s.leases[leaseID]; ok {
+		l.keys[key] = struct{}{}
+	}
+}
+
+// runLeaseExpiry на лидере отзывает просроченные аренды через лог, чтобы
+// ApplyLog оставался детерминированным.
+func (s *Store) runLeaseExpiry() {
+	ticker := time.NewTicker(leaseCheckInterval)
+	defer ticker.Stop()
+
+	wasLeader := false
+	for {
+		select {
+		case <-s.stopCh:
+			return
+		case <-ticker.C:
+		}
+
+		isLeader := s.raft.IsLeader()
+		if isLeader && !wasLeader {
+			s.extendLeases()
+		}
+		wasLeader = isLeader
+		if !isLeader {
+			continue
+		}
+
+		for _, id := range s.expiredLeases() {
+			go s.revokeExpired(id)
+		}
+	}
+}
+
+// extendLeases вызывается при получении лидерства: новый лидер не знает,
+// когда клиенты продлевали аренды у прежнего, и отсчитывает TTL заново.
+func (s *Store) extendLeases() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for _, l := range s.leases {
+		l.deadline = now.Add(l.ttl)
+		l.revoking = false
+	}
+}
+
+func (s *Store) expiredLeases() []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	var expired []int
+	for id, l := range s.leases {
+		if !l.revoking && now.After(l.deadline) {
+			l.revoking = true
+			expired = append(expired, id)
+		}
+	}
+	return expired
+}
+
+func (s *Store) revokeExpired(id int) {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultProposeTimeout)
+	defer cancel()
+
+	err := s.Revoke(ctx, id)
+	if err == nil || err == ErrLeaseNotFound {
+		return
+	}
+	log.Printf("kvstore: failed to revoke expired lease %d: %v", id, err)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if l, ok := s.leases[id]; ok {
+		l.revoking = false
+	}
+}
@@ -0,0 +1,222 @@
+package kvstore
+
+import (
+	"context"
+	"errors"
+	"sort"
+)
+
+const OpCompact = "COMPACT"
+
+var ErrFutureRevision = errors.New("kvstore: requested revision is newer than the applied state")
+
+// keyRevision — версия ключа на ревизии Revision (raft-индекс записи).
+// Deleted — надгробие: ключ удалён на этой ревизии.
+type keyRevision struct {
+	Revision int
+	Value    string
+	Lease    int
+	Deleted  bool
+}
+
+// keyHistory — формат истории ключа в снапшоте.
+type keyHistory struct {
+	Key  string
+	Revs []keyRevision
+}
+
+// putLocked и deleteLocked — единственные точки изменения данных в ApplyLog:
+// обновляют текущее состояние, историю версий и копят событие watch до
+// конца записи. Аренду leaseID вызывающий проверяет заранее.
+func (s *Store) putLocked(key, value string, leaseID, index int) {
+	prev, _ := s.data.Get(key)
+	s.attachLocked(key, prev.Lease, leaseID)
+
+	s.data.Set(KeyValue{Key: key, Value: value, Version: index, Lease: leaseID})
+	s.recordLocked(key, keyRevision{Revision: index, Value: value, Lease: leaseID})
+	s.pending = append(s.pending, Event{Type: EventPut, Key: key, Value: value, Revision: index})
+}
+
+func (s *Store) deleteLocked(key string, index int) {
+	prev, ok := s.data.Get(key)
+	if !ok {
+		return
+	}
+	s.attachLocked(key, prev.Lease, 0)
+	s.data.Delete(key)
+	s.recordLocked(key, keyRevision{Revision: index, Deleted: true})
+	s.pending = append(s.pending, Event{Type: EventDelete, Key: key, Revision: index})
+}
+
+// publishLocked рассылает события применённой записи в том же порядке,
+// в каком их восстанавливает eventsSinceLocked: по ключу, а для одного
+// ключа — по порядку операций.
+func (s *Store) publishLocked() {
+	sort.SliceStable(s.pending, func(i, j int) bool {
+		return s.pending[i].Key < s.pending[j].Key
+	})
+	for _, ev := range s.pending {
+		s.watches.publish(ev)
+	}
+	s.pending = s.pending[:0]
+}
+
+// recordLocked сохраняет каждую операцию: несколько операций над ключом в
+// одной транзакции дают несколько версий с одной ревизией, и replay отдаёт
+// все их события. Значение на ревизии — последняя из них.
+func (s *Store) recordLocked(key string, rev keyRevision) {
+	n := s.history.Set(KeyValue{Key: key})
+	n.revs = append(n.revs, rev)
+}
+
+// GetAt возвращает значение ключа на ревизии revision.
+func (s *Store) GetAt(key string, revision int) (KeyValue, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if err := s.checkRevisionLocked(revision); err != nil {
+		return KeyValue{}, err
+	}
+	n := s.history.Seek(key)
+	if n == nil || n.kv.Key != key {
+		return KeyValue{}, ErrKeyNotFound
+	}
+	if kv, ok := n.valueAt(revision); ok {
+		return kv, nil
+	}
+	return KeyValue{}, ErrKeyNotFound
+}
+
+// RangeAt — Range по состоянию на ревизии revision. Токен продолжения
+// действителен, пока revision не скомпактирована.
+func (s *Store) RangeAt(start, end string, limit, revision int) (ScanPage, error) {
+	limit = clampLimit(limit)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if err := s.checkRevisionLocked(revision); err != nil {
+		return ScanPage{}, err
+	}
+
+	page := ScanPage{Items: make([]KeyValue, 0), Revision: revision}
+	for n := s.history.Seek(start); n != nil; n = n.Next() {
+		if end != "" && n.kv.Key >= end {
+			break
+		}
+		kv, ok := n.valueAt(revision)
+		if !ok {
+			continue
+		}
+		if len(page.Items) == limit {
+			page.Next = EncodeToken(n.kv.Key)
+			break
+		}
+		page.Items = append(page.Items, kv)
+	}
+	return page, nil
+}
+
+// Revision возвращает индекс последней применённой записи и ревизию
+// последней компакции.
+func (s *Store) Revision() (current, compacted int) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.revision, s.compactRevision
+}
+
+// Compact реплицирует удаление версий старше revision. Чтения на ревизиях
+// меньше revision после этого возвращают ErrCompacted.
+func (s *Store) Compact(ctx context.Context, revision int) (int, error) {
+	s.mu.RLock()
+	err := s.checkRevisionLocked(revision)
+	s.mu.RUnlock()
+	if err != nil {
+		return 0, err
+	}
+
+	index, _, err := s.propose(ctx, Command{Op: OpCompact, Version: revision})
+	return index, err
+}
+
+// applyCompactLocked оставляет для каждого ключа все версии последней
+// ревизии не новее revision и всё, что после неё: watch с revision отдаёт
+// её события целиком. Удалённые до revision ключи исчезают.
+func (s *Store) applyCompactLocked(revision int) {
+	if revision <= s.compactRevision {
+		return
+	}
+
+	var removed []string
+	for n := s.history.First(); n != nil; n = n.Next() {
+		keep := 0
+		for i, rev := range n.revs {
+			if rev.Revision <= revision && (i == 0 || n.revs[i-1].Revision != rev.Revision) {
+				keep = i
+			}
+		}
+		n.revs = append(n.revs[:0:0], n.revs[keep:]...)
+		if last := n.revs[len(n.revs)-1]; last.Deleted && last.Revision < revision {
+			removed = append(removed, n.kv.Key)
+		}
+	}
+	for _, key := range removed {
+		s.history.Delete(key)
+	}
+	s.compactRevision = revision
+}
+
+func (s *Store) checkRevisionLocked(revision int) error {
+	if revision < s.compactRevision {
+		return ErrCompacted
+	}
+	if revision > s.revision {
+		return ErrFutureRevision
+	}
+	return nil
+}
+
+// eventsSinceLocked восстанавливает события с ревизией >= from по истории
+// версий, в порядке применения.
+func (s *Store) eventsSinceLocked(key string, prefix bool, from int) []Event {
+	end := key + "\x00"
+	if prefix {
+		end = PrefixEnd(key)
+	}
+
+	var events []Event
+	for n := s.history.Seek(key); n != nil; n = n.Next() {
+		if end != "" && n.kv.Key >= end {
+			break
+		}
+		for _, rev := range n.revs {
+			if rev.Revision < from {
+				continue
+			}
+			ev := Event{Type: EventPut, Key: n.kv.Key, Value: rev.Value, Revision: rev.Revision}
+			if rev.Deleted {
+				ev = Event{Type: EventDelete, Key: n.kv.Key, Revision: rev.Revision}
+			}
+			events = append(events, ev)
+		}
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Revision < events[j].Revision
+	})
+	return events
+}
+
+func (n *skipNode) valueAt(revision int) (KeyValue, bool) {
+	for i := len(n.revs) - 1; i >= 0; i-- {
+		rev := n.revs[i]
+		if rev.Revision > revision {
+			continue
+		}
+		if rev.Deleted {
+			return KeyValue{}, false
+		}
+		return KeyValue{Key: n.kv.Key, Value: rev.Value, Version: rev.Revision, Lease: rev.Lease}, true
+	}
+	return KeyValue{}, false
+}
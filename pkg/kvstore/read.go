@@ -0,0 +1,97 @@
+package kvstore
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+)
+
+type Consistency int
+
+const (
+	ConsistencyDefault Consistency = iota
+	ConsistencyLinearizable
+	ConsistencyLease
+	ConsistencyStale
+)
+
+var (
+	ErrInvalidConsistency = errors.New("kvstore: unknown consistency level")
+	ErrInvalidMaxLag      = errors.New("kvstore: max_lag must be a positive entry count or duration")
+)
+
+// ReadOptions задаёт уровень согласованности чтения. MaxLagEntries и
+// MaxLagTime используются только с ConsistencyStale. Revision > 0 — чтение
+// состояния на этой ревизии.
+type ReadOptions struct {
+	Consistency   Consistency
+	MaxLagEntries int
+	MaxLagTime    time.Duration
+	Revision      int
+}
+
+func ParseConsistency(s string) (Consistency, error) {
+	switch s {
+	case "", "default":
+		return ConsistencyDefault, nil
+	case "linearizable":
+		return ConsistencyLinearizable, nil
+	case "lease":
+		return ConsistencyLease, nil
+	case "stale":
+		return ConsistencyStale, nil
+	}
+	return ConsistencyDefault, ErrInvalidConsistency
+}
+
+// ParseReadOptions разбирает consistency и max_lag из запроса. max_lag —
+// либо число записей ("100"), либо длительность ("500ms"), и то и другое
+// больше нуля: нулевая граница означала бы чтение без ограничения.
+func ParseReadOptions(consistency, maxLag string) (ReadOptions, error) {
+	c, err := ParseConsistency(consistency)
+	if err != nil {
+		return ReadOptions{}, err
+	}
+
+	opts := ReadOptions{Consistency: c}
+	if c != ConsistencyStale {
+		return opts, nil
+	}
+
+	if n, err := strconv.Atoi(maxLag); err == nil && n > 0 {
+		opts.MaxLagEntries = n
+		return opts, nil
+	}
+	if d, err := time.ParseDuration(maxLag); err == nil && d > 0 {
+		opts.MaxLagTime = d
+		return opts, nil
+	}
+	return ReadOptions{}, ErrInvalidMaxLag
+}
+
+// GetConsistent читает ключ с заданным уровнем согласованности.
+// ConsistencyDefault читает локальное состояние без проверок.
+func (s *Store) GetConsistent(ctx context.Context, key string, opts ReadOptions) (KeyValue, error) {
+	if err := s.ensureConsistency(ctx, opts); err != nil {
+		return KeyValue{}, err
+	}
+	if opts.Revision > 0 {
+		return s.GetAt(key, opts.Revision)
+	}
+	return s.GetKV(key)
+}
+
+func (s *Store) ensureConsistency(ctx context.Context, opts ReadOptions) error {
+	switch opts.Consistency {
+	case ConsistencyLinearizable:
+		_, err := s.raft.ReadIndex(ctx)
+		return err
+	case ConsistencyLease:
+		_, err := s.raft.LeaseRead(ctx)
+		return err
+	case ConsistencyStale:
+		return s.raft.StaleRead(opts.MaxLagEntries, opts.MaxLagTime)
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package kvstore
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+)
+
+const (
+	DefaultScanLimit = 1000
+	MaxScanLimit     = 10000
+)
+
+var ErrInvalidToken = errors.New("kvstore: invalid continuation token")
+
+// ScanPage — страница результатов Range/Prefix. Next — токен продолжения,
+// пустой, если ключей больше нет. Следующие страницы читаются через RangeAt
+// с той же Revision, чтобы весь обход видел одно состояние.
+type ScanPage struct {
+	Items    []KeyValue `json:"items"`
+	Next     string     `json:"next,omitempty"`
+	Revision int        `json:"revision"`
+}
+
+// Range возвращает ключи из [start, end) по возрастанию. Пустой end —
+// без верхней границы.
+func (s *Store) Range(start, end string, limit int) ScanPage {
+	limit = clampLimit(limit)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	page := ScanPage{Items: make([]KeyValue, 0), Revision: s.revision}
+	for n := s.data.Seek(start); n != nil; n = n.Next() {
+		if end != "" && n.kv.Key >= end {
+			break
+		}
+		if len(page.Items) == limit {
+			page.Next = EncodeToken(n.kv.Key)
+			break
+		}
+		page.Items = append(page.Items, n.kv)
+	}
+	return page
+}
+
+func (s *Store) Prefix(prefix string, limit int) ScanPage {
+	return s.Range(prefix, PrefixEnd(prefix), limit)
+}
+
+// RangeConsistent — Range с проверкой уровня согласованности перед чтением.
+func (s *Store) RangeConsistent(ctx context.Context, start, end string, limit int, opts ReadOptions) (ScanPage, error) {
+	if err := s.ensureConsistency(ctx, opts); err != nil {
+		return ScanPage{}, err
+	}
+	if opts.Revision > 0 {
+		return s.RangeAt(start, end, limit, opts.Revision)
+	}
+	return s.Range(start, end, limit), nil
+}
+
+// ScanBounds сводит prefix, start и end к одному полуинтервалу [start, end).
+func ScanBounds(prefix, start, end string) (string, string) {
+	if prefix == "" {
+		return start, end
+	}
+	if start < prefix {
+		start = prefix
+	}
+	if prefixEnd := PrefixEnd(prefix); prefixEnd != "" && (end == "" || end > prefixEnd) {
+		end = prefixEnd
+	}
+	return start, end
+}
+
+// PrefixEnd возвращает наименьший ключ, больший всех ключей с префиксом p.
+func PrefixEnd(p string) string {
+	b := []byte(p)
+	for i := len(b) - 1; i >= 0; i-- {
+		if b[i] < 0xff {
+			b[i]++
+			return string(b[:i+1])
+		}
+	}
+	return ""
+}
+
+func EncodeToken(key string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(key))
+}
+
+func DecodeToken(token string) (string, error) {
+	key, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return "", ErrInvalidToken
+	}
+	return string(key), nil
+}
+
+func clampLimit(limit int) int {
+	if limit <= 0 {
+		return DefaultScanLimit
+	}
+	if limit > MaxScanLimit {
+		return MaxScanLimit
+	}
+	return limit
+}
@@ -0,0 +1,180 @@
+package kvstore
+
+import (
+	"context"
+	"encoding/gob"
+	"errors"
+	"log"
+	"time"
+)
+
+const (
+	OpExpireSessions = "EXPIRE_SESSIONS"
+
+	DefaultSessionTTL     = 10 * time.Minute
+	sessionExpiryInterval = time.Minute
+)
+
+var ErrStaleRequest = errors.New("kvstore: request sequence is older than the last applied one")
+
+// Ошибки, которые ApplyLog возвращает как результат команды. В снапшоте
+// они хранятся текстом и восстанавливаются по этой таблице.
+var applyErrors = map[string]error{
+	ErrLeaseNotFound.Error(): ErrLeaseNotFound,
+	ErrStaleRequest.Error():  ErrStaleRequest,
+}
+
+func init() {
+	// Закэшированные ответы сессий попадают в снапшот как interface{}
+	gob.Register(ApplyResult{})
+	gob.Register(TxnResult{})
+}
+
+type requestIDKey struct{}
+
+type requestID struct {
+	clientID string
+	seq      uint64
+}
+
+// WithRequestID помечает команды, предлагаемые с этим контекстом, ID клиента
+// и порядковым номером запроса. Повтор с тем же номером не применяется
+// повторно, а получает сохранённый ответ. Номера должны расти, у клиента
+// не больше одного запроса в полёте.
+func WithRequestID(ctx context.Context, clientID string, seq uint64) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID{clientID: clientID, seq: seq})
+}
+
+// clientSession — реплицируемая запись о последнем запросе клиента.
+// LastActive — метка времени лидера из команды, а не локальные часы, чтобы
+// истечение было одинаковым на всех узлах.
+type clientSession struct {
+	lastSeq    uint64
+	response   interface{}
+	lastActive int64
+}
+
+type sessionSnapshot struct {
+	ClientID   string
+	LastSeq    uint64
+	LastActive int64
+	Response   interface{}
+	Err        string
+}
+
+// stampCommand переносит ID запроса из ctx в команду.
+func stampCommand(ctx context.Context, cmd *Command) {
+	id, ok := ctx.Value(requestIDKey{}).(requestID)
+	if !ok || id.clientID == "" {
+		return
+	}
+	cmd.ClientID = id.clientID
+	cmd.Seq = id.seq
+	cmd.Timestamp = time.Now().UnixNano()
+}
+
+// lookupSessionLocked возвращает сохранённый ответ, если команда уже
+// применялась.
+func (s *Store) lookupSessionLocked(cmd Command) (interface{}, bool) {
+	sess, ok := s.sessions[cmd.ClientID]
+	if !ok || cmd.Seq > sess.lastSeq {
+		return nil, false
+	}
+	sess.lastActive = cmd.Timestamp
+	if cmd.Seq < sess.lastSeq {
+		return ErrStaleRequest, true
+	}
+	return sess.response, true
+}
+
+func (s *Store) saveSessionLocked(cmd Command, response interface{}) {
+	s.sessions[cmd.ClientID] = &clientSession{
+		lastSeq:    cmd.Seq,
+		response:   response,
+		lastActive: cmd.Timestamp,
+	}
+}
+
+func (s *Store) applyExpireSessionsLocked(cutoff int64) {
+	for id, sess := range s.sessions {
+		if sess.lastActive < cutoff {
+			delete(s.sessions, id)
+		}
+	}
+}
+
+func (s *Store) snapshotSessionsLocked() []sessionSnapshot {
+	snaps := make([]sessionSnapshot, 0, len(s.sessions))
+	for id, sess := range s.sessions {
+		snap := sessionSnapshot{
+			ClientID:   id,
+			LastSeq:    sess.lastSeq,
+			LastActive: sess.lastActive,
+			Response:   sess.response,
+		}
+		if err, ok := sess.response.(error); ok {
+			snap.Response, snap.Err = nil, err.Error()
+		}
+		snaps = append(snaps, snap)
+	}
+	return snaps
+}
+
+func restoreSessions(snaps []sessionSnapshot) map[string]*clientSession {
+	sessions := make(map[string]*clientSession, len(snaps))
+	for _, snap := range snaps {
+		sess := &clientSession{
+			lastSeq:    snap.LastSeq,
+			response:   snap.Response,
+			lastActive: snap.LastActive,
+		}
+		if snap.Err != "" {
+			sess.response = applyErrors[snap.Err]
+		}
+		sessions[snap.ClientID] = sess
+	}
+	return sessions
+}
+
+// runSessionExpiry на лидере периодически реплицирует отсечку: сессии без
+// запросов дольше sessionTTL удаляются на всех узлах.
+func (s *Store) runSessionExpiry() {
+	ticker := time.NewTicker(sessionExpiryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopCh:
+			return
+		case <-ticker.C:
+		}
+
+		if !s.raft.IsLeader() {
+			continue
+		}
+		s.mu.RLock()
+		empty, ttl := len(s.sessions) == 0, s.sessionTTL
+		s.mu.RUnlock()
+		if empty {
+			continue
+		}
+
+		cmd := Command{
+			Op:        OpExpireSessions,
+			Timestamp: time.Now().Add(-ttl).UnixNano(),
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), DefaultProposeTimeout)
+		if _, _, err := s.propose(ctx, cmd); err != nil {
+			log.Printf("kvstore: failed to expire client sessions: %v", err)
+		}
+		cancel()
+	}
+}
+
+// SetSessionTTL задаёт, сколько сессия клиента живёт без запросов. Влияет
+// только на отсечки, которые предлагает этот узел, будучи лидером.
+func (s *Store) SetSessionTTL(ttl time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessionTTL = ttl
+}
@@ -0,0 +1,129 @@
+package kvstore
+
+import "math/rand"
+
+const (
+	skipListMaxLevel = 24
+	skipListP        = 0.25
+)
+
+// skipList — упорядоченное по ключу хранилище KeyValue. Не потокобезопасен,
+// доступ защищается Store.mu. В списке истории Store узлы дополнительно
+// хранят версии ключа в revs.
+type skipList struct {
+	head   *skipNode
+	level  int
+	length int
+	rnd    *rand.Rand
+}
+
+type skipNode struct {
+	kv   KeyValue
+	revs []keyRevision
+	next []*skipNode
+}
+
+func newSkipList() *skipList {
+	return &skipList{
+		head:  &skipNode{next: make([]*skipNode, skipListMaxLevel)},
+		level: 1,
+		rnd:   rand.New(rand.NewSource(1)),
+	}
+}
+
+func (l *skipList) Len() int {
+	return l.length
+}
+
+func (l *skipList) Get(key string) (KeyValue, bool) {
+	n := l.Seek(key)
+	if n == nil || n.kv.Key != key {
+		return KeyValue{}, false
+	}
+	return n.kv, true
+}
+
+// Seek возвращает первый узел с ключом >= key.
+func (l *skipList) Seek(key string) *skipNode {
+	x := l.head
+	for i := l.level - 1; i >= 0; i-- {
+		for x.next[i] != nil && x.next[i].kv.Key < key {
+			x = x.next[i]
+		}
+	}
+	return x.next[0]
+}
+
+func (l *skipList) First() *skipNode {
+	return l.head.next[0]
+}
+
+// Set вставляет или заменяет kv и возвращает его узел.
+func (l *skipList) Set(kv KeyValue) *skipNode {
+	update := make([]*skipNode, skipListMaxLevel)
+	x := l.head
+	for i := l.level - 1; i >= 0; i-- {
+		for x.next[i] != nil && x.next[i].kv.Key < kv.Key {
+			x = x.next[i]
+		}
+		update[i] = x
+	}
+
+	if n := x.next[0]; n != nil && n.kv.Key == kv.Key {
+		n.kv = kv
+		return n
+	}
+
+	level := l.randomLevel()
+	if level > l.level {
+		for i := l.level; i < level; i++ {
+			update[i] = l.head
+		}
+		l.level = level
+	}
+
+	n := &skipNode{kv: kv, next: make([]*skipNode, level)}
+	for i := 0; i < level; i++ {
+		n.next[i] = update[i].next[i]
+		update[i].next[i] = n
+	}
+	l.length++
+	return n
+}
+
+func (l *skipList) Delete(key string) bool {
+	update := make([]*skipNode, skipListMaxLevel)
+	x := l.head
+	for i := l.level - 1; i >= 0; i-- {
+		for x.next[i] != nil && x.next[i].kv.Key < key {
+			x = x.next[i]
+		}
+		update[i] = x
+	}
+
+	n := x.next[0]
+	if n == nil || n.kv.Key != key {
+		return false
+	}
+
+	for i := 0; i < len(n.next); i++ {
+		update[i].next[i] = n.next[i]
+	}
+	for l.level > 1 && l.head.next[l.level-1] == nil {
+		l.level--
+	}
+	l.length--
+	return true
+}
+
+func (l *skipList) randomLevel() int {
+	level := 1
+	for level < skipListMaxLevel && l.rnd.Float64() < skipListP {
+		level++
+	}
+	return level
+}
+
+func (n *skipNode) Next() *skipNode {
+	return n.next[0]
+}
@@ -1,41 +1,309 @@
 package kvstore
 
 import (
-	"raft"
+	"bytes"
+	"context"
+	"encoding/gob"
+	"io"
+	"raft-kv-store/pkg/raft"
 	"sync"
+	"time"
 )
 
+const DefaultProposeTimeout = 5 * time.Second
+
+func init() {
+	// Команды пишутся в WAL как interface{}, gob должен знать конкретный тип
+	gob.Register(Command{})
+}
+
+// Store хранит текущее состояние в data и все версии ключей, включая
+// удалённые, в history — до явной компакции.
 type Store struct {
-	mu   sync.RWMutex
-	data map[string]string
-	raft *raft.RaftNode
+	mu              sync.RWMutex
+	data            *skipList
+	history         *skipList
+	revision        int
+	compactRevision int
+	leases          map[int]*lease
+	sessions        map[string]*clientSession
+	sessionTTL      time.Duration
+	watches         *watchHub
+	pending         []Event
+	raft            *raft.RaftNode
+	stopCh          chan struct{}
+	stopOnce        sync.Once
+}
+
+// storeSnapshot — формат снапшота: история ключей в порядке возрастания и
+// индекс последней применённой записи.
+type storeSnapshot struct {
+	Revision        int
+	CompactRevision int
+	History         []keyHistory
+	Leases          []leaseSnapshot
+	Sessions        []sessionSnapshot
+}
+
+func NewStore(raftNode *raft.RaftNode) *Store {
+	s := &Store{
+		data:       newSkipList(),
+		history:    newSkipList(),
+		leases:     make(map[int]*lease),
+		sessions:   make(map[string]*clientSession),
+		sessionTTL: DefaultSessionTTL,
+		watches:    newWatchHub(),
+		raft:       raftNode,
+		stopCh:     make(chan struct{}),
+	}
+	raftNode.SetFSM(s)
+	go s.runLeaseExpiry()
+	go s.runSessionExpiry()
+	return s
+}
+
+// Close останавливает фоновое истечение аренд и сессий и закрывает все
+// watch: подписчики переподключаются к другому узлу.
+func (s *Store) Close() {
+	s.stopOnce.Do(func() {
+		s.mu.Lock()
+		close(s.stopCh)
+		s.mu.Unlock()
+		s.watches.closeAll(ErrWatchCancelled)
+	})
 }
 
 func (s *Store) Get(key string) (string, error) {
+	kv, err := s.GetKV(key)
+	return kv.Value, err
+}
+
+func (s *Store) GetKV(key string) (KeyValue, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
-	if val, ok := s.data[key]; ok {
-		return val, nil
+	if kv, ok := s.data.Get(key); ok {
+		return kv, nil
 	}
-	return "", ErrKeyNotFound
+	return KeyValue{}, ErrKeyNotFound
+}
+
+// Propose возвращает управление, когда команда закоммичена и применена,
+// вместе с индексом записи в логе.
+func (s *Store) Propose(ctx context.Context, key, value string) (int, error) {
+	return s.proposeConditional(ctx, Command{Op: OpSet, Key: key, Value: value})
+}
+
+// PutWithLease записывает ключ, привязанный к аренде leaseID: ключ удаляется
+// при её отзыве или истечении.
+func (s *Store) PutWithLease(ctx context.Context, key, value string, leaseID int) (int, error) {
+	return s.proposeConditional(ctx, Command{Op: OpSet, Key: key, Value: value, Lease: leaseID})
+}
+
+func (s *Store) Put(key, value string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultProposeTimeout)
+	defer cancel()
+	_, err := s.Propose(ctx, key, value)
+	return err
 }
 
-func (s *Store) Propose(key, value string) error {
-	cmd := Command{
-		Op:    "SET",
-		Key:   key,
-		Value: value,
+// CompareAndSwap записывает value, только если текущее значение равно
+// prevValue. Ненулевой leaseID, как и в PutWithLease, привязывает ключ к аренде.
+func (s *Store) CompareAndSwap(ctx context.Context, key, prevValue, value string, leaseID int) (int, error) {
+	return s.proposeConditional(ctx, Command{
+		Op:        OpCompareAndSwap,
+		Key:       key,
+		Value:     value,
+		PrevValue: prevValue,
+		Lease:     leaseID,
+	})
+}
+
+func (s *Store) PutIfAbsent(ctx context.Context, key, value string, leaseID int) (int, error) {
+	return s.proposeConditional(ctx, Command{Op: OpPutIfAbsent, Key: key, Value: value, Lease: leaseID})
+}
+
+// Delete возвращает ErrKeyNotFound, если ключа не было на момент применения
+// команды.
+func (s *Store) Delete(ctx context.Context, key string) (int, error) {
+	return s.proposeConditional(ctx, Command{Op: OpDelete, Key: key})
+}
+
+func (s *Store) DeleteIfVersion(ctx context.Context, key string, version int) (int, error) {
+	return s.proposeConditional(ctx, Command{Op: OpDeleteIfVersion, Key: key, Version: version})
+}
+
+// proposeConditional возвращает версию из результата применения: записанную
+// командой, а при ErrConflict — текущую версию ключа. Повтор запроса
+// получает сохранённый результат и ту же версию, а не индекс своей записи.
+func (s *Store) proposeConditional(ctx context.Context, cmd Command) (int, error) {
+	index, result, err := s.propose(ctx, cmd)
+	if err != nil {
+		return index, err
+	}
+	if err, ok := result.(error); ok {
+		return index, err
 	}
-	return s.raft.Propose(cmd)
+
+	res, _ := result.(ApplyResult)
+	switch {
+	case cmd.Op == OpDelete && !res.Existed:
+		return index, ErrKeyNotFound
+	case !res.Succeeded:
+		return res.Version, ErrConflict
+	}
+	return res.Version, nil
+}
+
+func (s *Store) propose(ctx context.Context, cmd Command) (int, interface{}, error) {
+	stampCommand(ctx, &cmd)
+	future := s.raft.Propose(cmd)
+	result, err := future.Wait(ctx)
+	return future.Index(), result, err
 }
 
-func (s *Store) ApplyLog(cmd Command) {
+func (s *Store) Apply(entry raft.LogEntry) interface{} {
+	cmd, ok := entry.Command.(Command)
+	if !ok {
+		return nil
+	}
+	return s.ApplyLog(entry.Index, cmd)
+}
+
+// ApplyLog применяет команду с индексом index. Условия проверяются здесь,
+// на момент применения, одинаково на всех узлах. Повтор команды клиента
+// с уже применённым Seq возвращает сохранённый ответ.
+func (s *Store) ApplyLog(index int, cmd Command) interface{} {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	defer s.publishLocked()
+
+	s.revision = index
+	if cmd.ClientID == "" {
+		return s.applyLocked(index, cmd)
+	}
+	if response, ok := s.lookupSessionLocked(cmd); ok {
+		return response
+	}
+	response := s.applyLocked(index, cmd)
+	s.saveSessionLocked(cmd, response)
+	return response
+}
+
+func (s *Store) applyLocked(index int, cmd Command) interface{} {
 	switch cmd.Op {
-	case "SET":
-		s.data[cmd.Key] = cmd.Value
-	case "DELETE":
-		delete(s.data, cmd.Key)
+	case OpTxn:
+		return s.applyTxnLocked(index, cmd)
+	case OpCompact:
+		s.applyCompactLocked(cmd.Version)
+		return nil
+	case OpLeaseGrant:
+		return s.applyLeaseGrantLocked(index, cmd.TTL)
+	case OpLeaseRevoke:
+		return s.applyLeaseRevokeLocked(index, cmd.Lease)
+	case OpExpireSessions:
+		s.applyExpireSessionsLocked(cmd.Timestamp)
+		return nil
+	}
+
+	if cmd.Lease != 0 {
+		if _, ok := s.leases[cmd.Lease]; !ok {
+			return ErrLeaseNotFound
+		}
+	}
+
+	current, existed := s.data.Get(cmd.Key)
+	result := ApplyResult{Existed: existed, Version: current.Version}
+
+	switch cmd.Op {
+	case OpSet:
+		result.Succeeded = true
+	case OpDelete:
+		result.Succeeded = existed
+	case OpCompareAndSwap:
+		result.Succeeded = existed && current.Value == cmd.PrevValue
+	case OpPutIfAbsent:
+		result.Succeeded = !existed
+	case OpDeleteIfVersion:
+		result.Succeeded = existed && current.Version == cmd.Version
+	}
+	if !result.Succeeded {
+		return result
+	}
+
+	switch cmd.Op {
+	case OpSet, OpCompareAndSwap, OpPutIfAbsent:
+		s.putLocked(cmd.Key, cmd.Value, cmd.Lease, index)
+		result.Version = index
+	case OpDelete, OpDeleteIfVersion:
+		s.deleteLocked(cmd.Key, index)
+		result.Version = index
+	}
+	return result
+}
+
+// Snapshot сериализует историю ключей в порядке возрастания.
+func (s *Store) Snapshot() ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	snap := storeSnapshot{
+		Revision:        s.revision,
+		CompactRevision: s.compactRevision,
+		History:         make([]keyHistory, 0, s.history.Len()),
+	}
+	for n := s.history.First(); n != nil; n = n.Next() {
+		snap.History = append(snap.History, keyHistory{Key: n.kv.Key, Revs: n.revs})
+	}
+	for _, l := range s.leases {
+		snap.Leases = append(snap.Leases, leaseSnapshot{ID: l.id, TTL: l.ttl})
 	}
+	snap.Sessions = s.snapshotSessionsLocked()
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(snap); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (s *Store) Restore(r io.Reader) error {
+	var snap storeSnapshot
+	if err := gob.NewDecoder(r).Decode(&snap); err != nil {
+		return err
+	}
+
+	// Сроки аренд не реплицируются: после восстановления отсчёт TTL
+	// начинается заново
+	leases := make(map[int]*lease, len(snap.Leases))
+	for _, ls := range snap.Leases {
+		leases[ls.ID] = &lease{
+			id:       ls.ID,
+			ttl:      ls.TTL,
+			keys:     make(map[string]struct{}),
+			deadline: time.Now().Add(ls.TTL),
+		}
+	}
+
+	data, history := newSkipList(), newSkipList()
+	for _, h := range snap.History {
+		history.Set(KeyValue{Key: h.Key}).revs = h.Revs
+		if last := h.Revs[len(h.Revs)-1]; !last.Deleted {
+			data.Set(KeyValue{Key: h.Key, Value: last.Value, Version: last.Revision, Lease: last.Lease})
+			if l, ok := leases[last.Lease]; ok {
+				l.keys[h.Key] = struct{}{}
+			}
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data = data
+	s.history = history
+	s.leases = leases
+	s.sessions = restoreSessions(snap.Sessions)
+	s.revision = snap.Revision
+	s.compactRevision = snap.CompactRevision
+	// Подписчики переподключаются с последней полученной ревизии
+	s.watches.closeAll(ErrWatchCancelled)
+	return nil
 }
@@ -0,0 +1,198 @@
+package kvstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+const (
+	OpTxn = "TXN"
+	OpGet = "GET"
+)
+
+const (
+	CompareValue   = "value"
+	CompareVersion = "version"
+
+	CompareEqual    = "equal"
+	CompareNotEqual = "not_equal"
+	CompareGreater  = "greater"
+	CompareLess     = "less"
+)
+
+var ErrInvalidTxn = errors.New("kvstore: invalid transaction")
+
+// Compare — условие транзакции над текущим значением или версией ключа.
+// Отсутствующий ключ имеет пустое значение и версию 0.
+type Compare struct {
+	Key     string `json:"key"`
+	Target  string `json:"target"`
+	Result  string `json:"result"`
+	Value   string `json:"value,omitempty"`
+	Version int    `json:"version,omitempty"`
+}
+
+// Txn применяется одной записью лога: если все Compares выполняются,
+// применяются операции Success, иначе — Failure.
+type Txn struct {
+	Compares []Compare `json:"compare"`
+	Success  []Command `json:"success"`
+	Failure  []Command `json:"failure"`
+}
+
+type OpResult struct {
+	Op      string `json:"op"`
+	Key     string `json:"key"`
+	Value   string `json:"value,omitempty"`
+	Version int    `json:"version"`
+	Existed bool   `json:"existed"`
+}
+
+type TxnResult struct {
+	Succeeded bool       `json:"succeeded"`
+	Results   []OpResult `json:"results"`
+}
+
+func (t Txn) Validate() error {
+	for _, c := range t.Compares {
+		if c.Key == "" {
+			return fmt.Errorf("%w: compare without key", ErrInvalidTxn)
+		}
+		switch c.Target {
+		case CompareValue, CompareVersion:
+		default:
+			return fmt.Errorf("%w: unknown compare target %q", ErrInvalidTxn, c.Target)
+		}
+		switch c.Result {
+		case CompareEqual, CompareNotEqual, CompareGreater, CompareLess:
+		default:
+			return fmt.Errorf("%w: unknown compare result %q", ErrInvalidTxn, c.Result)
+		}
+	}
+	for _, ops := range [][]Command{t.Success, t.Failure} {
+		for _, op := range ops {
+			if op.Key == "" {
+				return fmt.Errorf("%w: operation without key", ErrInvalidTxn)
+			}
+			switch op.Op {
+			case OpSet, OpDelete, OpGet:
+			default:
+				return fmt.Errorf("%w: operation %q is not allowed in a transaction", ErrInvalidTxn, op.Op)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Store) Txn(ctx context.Context, txn Txn) (TxnResult, error) {
+	if err := txn.Validate(); err != nil {
+		return TxnResult{}, err
+	}
+
+	cmd := Command{
+		Op:       OpTxn,
+		Compares: txn.Compares,
+		Success:  txn.Success,
+		Failure:  txn.Failure,
+	}
+	_, result, err := s.propose(ctx, cmd)
+	if err != nil {
+		return TxnResult{}, err
+	}
+	if err, ok := result.(error); ok {
+		return TxnResult{}, err
+	}
+	res, _ := result.(TxnResult)
+	return res, nil
+}
+
+// applyTxnLocked вызывается из ApplyLog под s.mu. Если операция ссылается
+// на несуществующую аренду, транзакция не применяется целиком.
+func (s *Store) applyTxnLocked(index int, cmd Command) interface{} {
+	result := TxnResult{Succeeded: true}
+	for _, c := range cmd.Compares {
+		if !s.compareLocked(c) {
+			result.Succeeded = false
+			break
+		}
+	}
+
+	ops := cmd.Success
+	if !result.Succeeded {
+		ops = cmd.Failure
+	}
+	for _, op := range ops {
+		if op.Op != OpSet || op.Lease == 0 {
+			continue
+		}
+		if _, ok := s.leases[op.Lease]; !ok {
+			return ErrLeaseNotFound
+		}
+	}
+
+	for _, op := range ops {
+		current, existed := s.data.Get(op.Key)
+		res := OpResult{Op: op.Op, Key: op.Key, Existed: existed, Version: current.Version}
+
+		switch op.Op {
+		case OpGet:
+			res.Value = current.Value
+		case OpSet:
+			s.putLocked(op.Key, op.Value, op.Lease, index)
+			res.Version = index
+		case OpDelete:
+			// Как и одиночный Delete: ревизия удаления, если ключ был
+			if existed {
+				s.deleteLocked(op.Key, index)
+				res.Version = index
+			}
+		}
+		result.Results = append(result.Results, res)
+	}
+	return result
+}
+
+func (s *Store) compareLocked(c Compare) bool {
+	current, _ := s.data.Get(c.Key)
+
+	var cmp int
+	switch c.Target {
+	case CompareVersion:
+		cmp = compareInts(current.Version, c.Version)
+	default:
+		cmp = compareStrings(current.Value, c.Value)
+	}
+
+	switch c.Result {
+	case CompareEqual:
+		return cmp == 0
+	case CompareNotEqual:
+		return cmp != 0
+	case CompareGreater:
+		return cmp > 0
+	case CompareLess:
+		return cmp < 0
+	}
+	return false
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func compareStrings(a, b string) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
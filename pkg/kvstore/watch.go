@@ -0,0 +1,169 @@
+package kvstore
+
+import (
+	"errors"
+	"strings"
+	"sync"
+)
+
+const (
+	EventPut    = "PUT"
+	EventDelete = "DELETE"
+
+	watchChanSize = 1024
+)
+
+var (
+	ErrCompacted      = errors.New("kvstore: requested revision has been compacted")
+	ErrWatcherLagging = errors.New("kvstore: watcher fell behind and was cancelled")
+	ErrWatchCancelled = errors.New("kvstore: watch cancelled, resume from the last received revision")
+)
+
+// Event — изменение ключа. Revision — raft-индекс записи, которая его внесла.
+// События одной ревизии идут по возрастанию ключа, поэтому продолжить
+// прерванную ревизию можно, пропустив уже полученные из неё события.
+type Event struct {
+	Type     string `json:"type"`
+	Key      string `json:"key"`
+	Value    string `json:"value,omitempty"`
+	Revision int    `json:"revision"`
+}
+
+type Watcher struct {
+	id     int
+	key    string
+	prefix bool
+	events chan Event
+	start  int
+	hub    *watchHub
+	err    error
+}
+
+// Events закрывается при Close или при ошибке; причину возвращает Err.
+func (w *Watcher) Events() <-chan Event {
+	return w.events
+}
+
+// StartRevision — ревизия, с которой watcher отдаёт события. Для
+// fromRevision = 0 это следующая за применённой на момент подписки.
+func (w *Watcher) StartRevision() int {
+	return w.start
+}
+
+func (w *Watcher) Err() error {
+	w.hub.mu.Lock()
+	defer w.hub.mu.Unlock()
+	return w.err
+}
+
+func (w *Watcher) Close() {
+	w.hub.remove(w, nil)
+}
+
+func (w *Watcher) matches(key string) bool {
+	if w.prefix {
+		return strings.HasPrefix(key, w.key)
+	}
+	return key == w.key
+}
+
+// watchHub рассылает события подписчикам. publish вызывается из ApplyLog
+// и никогда не блокируется: отстающий watcher отключается с
+// ErrWatcherLagging.
+type watchHub struct {
+	mu       sync.Mutex
+	watchers map[int]*Watcher
+	nextID   int
+}
+
+func newWatchHub() *watchHub {
+	return &watchHub{watchers: make(map[int]*Watcher)}
+}
+
+// Watch подписывается на изменения ключа (или префикса при prefix=true).
+// При fromRevision > 0 сначала отдаются события начиная с этой ревизии,
+// восстановленные по истории версий.
+func (s *Store) Watch(key string, prefix bool, fromRevision int) (*Watcher, error) {
+	// s.mu держится до регистрации, чтобы между replay и подпиской
+	// не применилась ни одна запись
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	select {
+	case <-s.stopCh:
+		return nil, ErrWatchCancelled
+	default:
+	}
+
+	var replay []Event
+	if fromRevision > 0 {
+		if fromRevision < s.compactRevision {
+			return nil, ErrCompacted
+		}
+		replay = s.eventsSinceLocked(key, prefix, fromRevision)
+	} else {
+		fromRevision = s.revision + 1
+	}
+	return s.watches.watch(key, prefix, fromRevision, replay), nil
+}
+
+func (h *watchHub) watch(key string, prefix bool, start int, replay []Event) *Watcher {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	w := &Watcher{
+		id:     h.nextID,
+		key:    key,
+		prefix: prefix,
+		start:  start,
+		hub:    h,
+	}
+	h.nextID++
+
+	w.events = make(chan Event, watchChanSize+len(replay))
+	for _, ev := range replay {
+		w.events <- ev
+	}
+	h.watchers[w.id] = w
+	return w
+}
+
+func (h *watchHub) publish(ev Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, w := range h.watchers {
+		if !w.matches(ev.Key) {
+			continue
+		}
+		select {
+		case w.events <- ev:
+		default:
+			h.removeLocked(w, ErrWatcherLagging)
+		}
+	}
+}
+
+func (h *watchHub) closeAll(err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, w := range h.watchers {
+		h.removeLocked(w, err)
+	}
+}
+
+func (h *watchHub) remove(w *Watcher, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.removeLocked(w, err)
+}
+
+func (h *watchHub) removeLocked(w *Watcher, err error) {
+	if _, ok := h.watchers[w.id]; !ok {
+		return
+	}
+	delete(h.watchers, w.id)
+	w.err = err
+	close(w.events)
+}
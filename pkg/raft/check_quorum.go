@@ -0,0 +1,31 @@
+package raft
+
+import (
+	"sort"
+	"time"
+)
+
+// quorumContact сообщает, что за последний minElectionTimeout лидер получил
+// ответы на AppendEntries от кворума голосующих участников. Первый таймаут
+// после избрания отсчитывается от момента избрания. Вызывается под rn.mu.
+func (rn *RaftNode) quorumContact(now time.Time) bool {
+	acks := make([]time.Time, 0, len(rn.peers)+1)
+	if rn.isVoter() {
+		acks = append(acks, now)
+	}
+	for peerID := range rn.peers {
+		if !rn.learners[peerID] {
+			acks = append(acks, rn.lastAck[peerID])
+		}
+	}
+
+	quorum := rn.quorumSize()
+	contact := rn.leaderSince
+	if len(acks) >= quorum {
+		sort.Slice(acks, func(i, j int) bool { return acks[i].After(acks[j]) })
+		if acks[quorum-1].After(contact) {
+			contact = acks[quorum-1]
+		}
+	}
+	return now.Sub(contact) < minElectionTimeout
+}
@@ -0,0 +1,54 @@
+package raft
+
+func (rn *RaftNode) shouldSnapshot(config SnapshotConfig) bool {
+	rn.mu.Lock()
+	defer rn.mu.Unlock()
+
+	if config.Threshold > 0 && rn.lastApplied-rn.lastSnapshotIndex >= config.Threshold {
+		return true
+	}
+	return config.ThresholdBytes > 0 && rn.bytesSinceSnapshot >= config.ThresholdBytes
+}
+
+// takeSnapshot снимает состояние автомата, сохраняет его в SnapshotStore и
+// обрезает лог до LastIncludedIndex, оставляя trailingLogs последних записей.
+func (rn *RaftNode) takeSnapshot(trailingLogs int) error {
+	index, data, err := rn.snapshotFSM()
+	if err != nil {
+		return err
+	}
+
+	rn.mu.Lock()
+	if index <= rn.lastSnapshotIndex || index > rn.lastApplied {
+		rn.mu.Unlock()
+		return nil
+	}
+	term := rn.termAt(index)
+	cfg := rn.configAt(index)
+	rn.mu.Unlock()
+
+	if err := rn.snapshots.CreateSnapshot(index, term, cfg, data); err != nil {
+		return err
+	}
+
+	rn.mu.Lock()
+	defer rn.mu.Unlock()
+	rn.compactLog(index, term, trailingLogs)
+	return nil
+}
+
+func (rn *RaftNode) compactLog(index, term, trailingLogs int) {
+	if index <= rn.lastSnapshotIndex {
+		return
+	}
+
+	first := rn.firstLogIndex()
+	rn.lastSnapshotIndex = index
+	rn.lastSnapshotTerm = term
+	rn.bytesSinceSnapshot = 0
+	rn.trimConfigs(index)
+
+	if cut := index - trailingLogs; cut >= first {
+		rn.deleteLogs(first, cut)
+	}
+}
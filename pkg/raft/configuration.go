@@ -0,0 +1,324 @@
+package raft
+
+import (
+	"encoding/gob"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrConfigChangePending = errors.New("raft: previous configuration change is not committed yet")
+	ErrMemberExists        = errors.New("raft: member already exists")
+	ErrMemberNotFound      = errors.New("raft: member not found")
+)
+
+func init() {
+	// Конфигурации пишутся в лог как interface{} вместе с командами FSM
+	gob.Register(Configuration{})
+}
+
+// DefaultLearnerPromoteLag — на сколько записей matchIndex ученика может
+// отставать от commitIndex, чтобы лидер сделал его голосующим.
+const DefaultLearnerPromoteLag = 100
+
+// Member с Learner получает записи и снапшоты, но не голосует и не входит
+// в кворум.
+type Member struct {
+	ID      int    `json:"id"`
+	Address string `json:"address"`
+	Learner bool   `json:"learner,omitempty"`
+}
+
+// Configuration — состав кластера. Запись конфигурации действует с момента
+// добавления в лог, не дожидаясь коммита. Лидер допускает только одно
+// незакоммиченное изменение, и каждое добавляет или убирает одного участника,
+// поэтому кворумы старого и нового состава всегда пересекаются.
+type Configuration struct {
+	Members []Member
+}
+
+func (c Configuration) member(id int) (Member, bool) {
+	for _, m := range c.Members {
+		if m.ID == id {
+			return m, true
+		}
+	}
+	return Member{}, false
+}
+
+func (c Configuration) voters() int {
+	n := 0
+	for _, m := range c.Members {
+		if !m.Learner {
+			n++
+		}
+	}
+	return n
+}
+
+// configEntry — конфигурация и индекс записи, которая её ввела. Начальная
+// конфигурация из флагов имеет индекс 0.
+type configEntry struct {
+	index  int
+	config Configuration
+}
+
+// BootstrapConfiguration строит начальный состав из списка пиров. Пир
+// задаётся как "id=addr" либо просто адресом — тогда id назначаются по
+// порядку, пропуская собственный.
+func BootstrapConfiguration(id int, peers []string) Configuration {
+	cfg := Configuration{Members: []Member{{ID: id}}}
+	next := 1
+	for _, peer := range peers {
+		if peer == "" {
+			continue
+		}
+		if i := strings.IndexByte(peer, '='); i > 0 {
+			if peerID, err := strconv.Atoi(peer[:i]); err == nil {
+				cfg.Members = append(cfg.Members, Member{ID: peerID, Address: peer[i+1:]})
+				continue
+			}
+		}
+		if next == id {
+			next++
+		}
+		cfg.Members = append(cfg.Members, Member{ID: next, Address: peer})
+		next++
+	}
+	sort.Slice(cfg.Members, func(i, j int) bool { return cfg.Members[i].ID < cfg.Members[j].ID })
+	return cfg
+}
+
+// SetJoining запускает узел без конфигурации: он не выдвигается, пока лидер
+// не добавит его через AddMember и не реплицирует ему лог.
+func (rn *RaftNode) SetJoining() {
+	rn.mu.Lock()
+	defer rn.mu.Unlock()
+	rn.configs[0] = configEntry{}
+	rn.applyConfiguration()
+}
+
+// Configuration возвращает действующий состав кластера.
+func (rn *RaftNode) Configuration() Configuration {
+	rn.mu.Lock()
+	defer rn.mu.Unlock()
+	return rn.configuration()
+}
+
+// SetLearnerPromoteLag задаёт отставание, при котором ученик становится
+// голосующим.
+func (rn *RaftNode) SetLearnerPromoteLag(lag int) {
+	rn.mu.Lock()
+	defer rn.mu.Unlock()
+	rn.promoteLag = lag
+}
+
+// AddMember предлагает конфигурацию с новым участником-учеником: в кворум он
+// войдёт, когда догонит лидера. Future разрешается, когда запись закоммичена.
+func (rn *RaftNode) AddMember(id int, addr string) *ApplyFuture {
+	rn.mu.Lock()
+	defer rn.mu.Unlock()
+
+	cfg := rn.configuration()
+	if _, ok := cfg.member(id); ok {
+		return failedFuture(ErrMemberExists)
+	}
+	members := append(append([]Member(nil), cfg.Members...), Member{ID: id, Address: addr, Learner: true})
+	sort.Slice(members, func(i, j int) bool { return members[i].ID < members[j].ID })
+	return rn.proposeConfiguration(Configuration{Members: members})
+}
+
+// RemoveMember предлагает конфигурацию без участника id. Если это сам
+// лидер, он слагает полномочия после коммита записи.
+func (rn *RaftNode) RemoveMember(id int) *ApplyFuture {
+	rn.mu.Lock()
+	defer rn.mu.Unlock()
+
+	cfg := rn.configuration()
+	if _, ok := cfg.member(id); !ok {
+		return failedFuture(ErrMemberNotFound)
+	}
+	members := make([]Member, 0, len(cfg.Members)-1)
+	for _, m := range cfg.Members {
+		if m.ID != id {
+			members = append(members, m)
+		}
+	}
+	return rn.proposeConfiguration(Configuration{Members: members})
+}
+
+func (rn *RaftNode) proposeConfiguration(cfg Configuration) *ApplyFuture {
+	if rn.state != Leader {
+		return failedFuture(ErrNotLeader)
+	}
+	if rn.transferring {
+		return failedFuture(ErrTransferInProgress)
+	}
+	if rn.configIndex() > rn.commitIndex {
+		return failedFuture(ErrConfigChangePending)
+	}
+
+	entry := LogEntry{
+		Index:   rn.lastLogIndex() + 1,
+		Term:    rn.currentTerm,
+		Command: cfg,
+	}
+	future := newApplyFuture(entry.Index, entry.Term)
+	rn.trackFuture(future)
+	rn.storeLogs([]LogEntry{entry})
+	// Без других голосующих ответов AppendEntries не будет: фиксируем сразу
+	rn.updateCommitIndex()
+	go rn.broadcastAppendEntries()
+	return future
+}
+
+func (rn *RaftNode) configuration() Configuration {
+	return rn.configs[len(rn.configs)-1].config
+}
+
+func (rn *RaftNode) configIndex() int {
+	return rn.configs[len(rn.configs)-1].index
+}
+
+// configAt возвращает конфигурацию, действовавшую на момент записи index.
+func (rn *RaftNode) configAt(index int) Configuration {
+	for i := len(rn.configs) - 1; i > 0; i-- {
+		if rn.configs[i].index <= index {
+			return rn.configs[i].config
+		}
+	}
+	return rn.configs[0].config
+}
+
+func (rn *RaftNode) isVoter() bool {
+	m, ok := rn.configuration().member(rn.id)
+	return ok && !m.Learner
+}
+
+// maybePromote делает ученика голосующим, когда его matchIndex догнал
+// commitIndex с точностью до promoteLag. Вызывается лидером после
+// успешного AppendEntries.
+func (rn *RaftNode) maybePromote(peerID int) {
+	if !rn.learners[peerID] || rn.configIndex() > rn.commitIndex {
+		return
+	}
+	match := rn.matchIndex[peerID]
+	if match < rn.configIndex() || rn.commitIndex-match > rn.promoteLag {
+		return
+	}
+
+	cfg := rn.configuration()
+	members := make([]Member, len(cfg.Members))
+	for i, m := range cfg.Members {
+		if m.ID == peerID {
+			m.Learner = false
+		}
+		members[i] = m
+	}
+	rn.proposeConfiguration(Configuration{Members: members})
+}
+
+// loadConfigs восстанавливает историю конфигураций из записей лога при
+// старте узла.
+func (rn *RaftNode) loadConfigs() {
+	for i := rn.firstLogIndex(); i <= rn.lastLogIndex(); i++ {
+		if cfg, ok := rn.entryAt(i).Command.(Configuration); ok {
+			rn.configs = append(rn.configs, configEntry{index: i, config: cfg})
+		}
+	}
+	rn.applyConfiguration()
+}
+
+// observeConfigs вызывается для каждой добавленной в лог пачки записей.
+func (rn *RaftNode) observeConfigs(entries []LogEntry) {
+	changed := false
+	for _, entry := range entries {
+		if cfg, ok := entry.Command.(Configuration); ok {
+			rn.configs = append(rn.configs, configEntry{index: entry.Index, config: cfg})
+			changed = true
+		}
+	}
+	if changed {
+		rn.applyConfiguration()
+	}
+}
+
+// truncateConfigs откатывает конфигурации из удалённого хвоста лога,
+// начиная с index.
+func (rn *RaftNode) truncateConfigs(index int) {
+	n := len(rn.configs)
+	for n > 1 && rn.configs[n-1].index >= index {
+		n--
+	}
+	if n == len(rn.configs) {
+		return
+	}
+	rn.configs = rn.configs[:n]
+	rn.applyConfiguration()
+}
+
+// trimConfigs забывает конфигурации, ушедшие в снапшот по index, кроме
+// последней из них.
+func (rn *RaftNode) trimConfigs(index int) {
+	i := 0
+	for i+1 < len(rn.configs) && rn.configs[i+1].index <= index {
+		i++
+	}
+	rn.configs = rn.configs[i:]
+}
+
+// restoreConfigs заменяет конфигурации до index включительно конфигурацией
+// из снапшота; записи хвоста лога после снапшота остаются в силе.
+func (rn *RaftNode) restoreConfigs(index int, cfg Configuration) {
+	configs := []configEntry{{index: index, config: cfg}}
+	lastIndex := rn.lastLogIndex()
+	for _, c := range rn.configs {
+		if c.index > index && c.index <= lastIndex {
+			configs = append(configs, c)
+		}
+	}
+	rn.configs = configs
+	rn.applyConfiguration()
+}
+
+// applyConfiguration пересобирает peers из действующей конфигурации, а на
+// лидере заводит и убирает состояние репликации участников.
+func (rn *RaftNode) applyConfiguration() {
+	cfg := rn.configuration()
+	peers := make(map[int]string, len(cfg.Members))
+	learners := make(map[int]bool)
+	for _, m := range cfg.Members {
+		if m.ID == rn.id {
+			continue
+		}
+		peers[m.ID] = m.Address
+		if m.Learner {
+			learners[m.ID] = true
+		}
+		if _, ok := rn.nextIndex[m.ID]; rn.state == Leader && !ok {
+			rn.nextIndex[m.ID] = rn.lastLogIndex() + 1
+			rn.matchIndex[m.ID] = 0
+		}
+	}
+	for peerID := range rn.nextIndex {
+		if _, ok := peers[peerID]; !ok {
+			delete(rn.nextIndex, peerID)
+			delete(rn.matchIndex, peerID)
+			delete(rn.lastAck, peerID)
+		}
+	}
+	rn.peers = peers
+	rn.learners = learners
+}
+
+// checkRemoved снимает лидерство с узла, исключившего себя, как только
+// новая конфигурация закоммичена.
+func (rn *RaftNode) checkRemoved() {
+	if rn.state != Leader || rn.isVoter() || rn.commitIndex < rn.configIndex() {
+		return
+	}
+	rn.resolveFuture(rn.configIndex(), rn.currentTerm, nil)
+	rn.becomeFollower()
+}
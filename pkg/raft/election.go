@@ -2,6 +2,7 @@ package raft
 
 import (
 	"context"
+	"log"
 	"math/rand"
 	"time"
 )
@@ -11,6 +12,21 @@ const (
 	maxElectionTimeout = 3000 * time.Millisecond
 )
 
+// LeadershipTransfer выставляет кандидат, получивший TimeoutNow: такой
+// запрос фоловеры не отклоняют из-за живого лидера.
+type RequestVoteArgs struct {
+	Term               int
+	CandidateID        int
+	LastLogIndex       int
+	LastLogTerm        int
+	LeadershipTransfer bool
+}
+
+type RequestVoteReply struct {
+	Term        int
+	VoteGranted bool
+}
+
 func (rn *RaftNode) runElectionTimer() {
 	timeout := randomTimeout()
 	for {
@@ -19,14 +35,26 @@ func (rn *RaftNode) runElectionTimer() {
 			return
 		case <-time.After(timeout):
 			rn.mu.Lock()
+			// Лидер таймер не использует, но после сложения полномочий
+			// снова начнёт отсчитывать таймауты
 			if rn.state == Leader {
 				rn.mu.Unlock()
-				return
+				timeout = randomTimeout()
+				continue
+			}
+			// Узел вне конфигурации — ещё не добавленный или уже исключённый —
+			// не выдвигается
+			if !rn.isVoter() {
+				rn.mu.Unlock()
+				timeout = randomTimeout()
+				continue
 			}
 
-			rn.startNewElection()
+			// Узел остаётся фоловером, пока PreVote не наберёт кворум, и
+			// продолжает отсчитывать таймауты
+			rn.startPreVote()
 			rn.mu.Unlock()
-			return
+			timeout = randomTimeout()
 
 		case <-rn.resetTimerCh:
 			timeout = randomTimeout()
@@ -34,24 +62,37 @@ func (rn *RaftNode) runElectionTimer() {
 	}
 }
 
-func (rn *RaftNode) startNewElection() {
+func (rn *RaftNode) startNewElection(transfer bool) {
 	rn.currentTerm++
 	rn.state = Candidate
+	rn.leaderAddr = ""
 	rn.votedFor = rn.id
-	rn.votesReceived = 1
+	rn.persistState()
 
 	lastLogIndex, lastLogTerm := rn.getLastLogInfo()
 
 	args := RequestVoteArgs{
-		Term:         rn.currentTerm,
-		CandidateID:  rn.id,
-		LastLogIndex: lastLogIndex,
-		LastLogTerm:  lastLogTerm,
+		Term:               rn.currentTerm,
+		CandidateID:        rn.id,
+		LastLogIndex:       lastLogIndex,
+		LastLogTerm:        lastLogTerm,
+		LeadershipTransfer: transfer,
+	}
+
+	quorum := rn.quorumSize()
+	if quorum <= 1 {
+		rn.becomeLeader()
+		return
 	}
 
 	voteChan := make(chan bool, len(rn.peers))
+	voters := 0
 
-	for _, peer := range rn.peers {
+	for peerID, peer := range rn.peers {
+		if rn.learners[peerID] {
+			continue
+		}
+		voters++
 		go func(peer string) {
 			ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
 			defer cancel()
@@ -80,44 +121,110 @@ func (rn *RaftNode) startNewElection() {
 		}(peer)
 	}
 
-	go rn.countVotes(voteChan, len(rn.peers))
+	go rn.countVotes(voteChan, voters, quorum, args.Term)
 }
 
-func (rn *RaftNode) countVotes(voteChan <-chan bool, peersCount int) {
-	rn.mu.Lock()
-	defer rn.mu.Unlock()
-
-	quorum := (peersCount+1)/2 + 1
+// countVotes ждёт голоса без rn.mu: горутины голосования берут его сами.
+// Кворум зафиксирован по конфигурации на момент начала выборов.
+func (rn *RaftNode) countVotes(voteChan <-chan bool, peersCount, quorum, term int) {
 	votes := 1
-
 	for i := 0; i < peersCount; i++ {
-		if <-voteChan {
-			votes++
-			if votes >= quorum && rn.state == Candidate {
-				rn.becomeLeader()
-				return
-			}
+		if !<-voteChan {
+			continue
 		}
+		votes++
+		if votes < quorum {
+			continue
+		}
+
+		rn.mu.Lock()
+		if rn.state == Candidate && rn.currentTerm == term {
+			rn.becomeLeader()
+		}
+		rn.mu.Unlock()
+		return
 	}
 }
 
 func (rn *RaftNode) becomeLeader() {
 	rn.state = Leader
+	rn.leaderSince = time.Now()
 	rn.nextIndex = make(map[int]int)
 	rn.matchIndex = make(map[int]int)
+	rn.lastAck = make(map[int]time.Time)
 
-	for _, peer := range rn.peers {
-		rn.nextIndex[peer.id] = rn.log.LastIndex() + 1
-		rn.matchIndex[peer.id] = 0
+	// Пустая запись своего терма: без неё commitIndex нового лидера
+	// не подтверждён и ReadIndex не может отвечать.
+	lastIndex := rn.lastLogIndex()
+	rn.storeLogs([]LogEntry{{Index: lastIndex + 1, Term: rn.currentTerm}})
+
+	for peerID := range rn.peers {
+		rn.nextIndex[peerID] = lastIndex + 1
+		rn.matchIndex[peerID] = 0
 	}
+	rn.updateCommitIndex()
 
 	go rn.sendHeartbeats()
 }
 
 func (rn *RaftNode) stepDown(term int) {
-	rn.state = Follower
+	rn.becomeFollower()
 	rn.currentTerm = term
 	rn.votedFor = -1
+	rn.persistState()
+}
+
+// becomeFollower снимает лидерство, не меняя терм и votedFor.
+func (rn *RaftNode) becomeFollower() {
+	if rn.state == Leader {
+		rn.failPending(ErrLeadershipLost)
+		rn.failReadRequests(ErrLeadershipLost)
+	}
+	rn.state = Follower
+	rn.leaderAddr = ""
+}
+
+func (rn *RaftNode) HandleRequestVote(args *RequestVoteArgs, reply *RequestVoteReply) {
+	rn.mu.Lock()
+	defer rn.mu.Unlock()
+
+	// Пока лидер на связи, запрос не может сместить его даже большим
+	// термом: на этом держатся lease-чтения, а кандидат, скорее всего,
+	// был отрезан от кластера
+	if rn.hasLiveLeader() && !args.LeadershipTransfer {
+		reply.Term = rn.currentTerm
+		reply.VoteGranted = false
+		return
+	}
+
+	if args.Term > rn.currentTerm {
+		rn.stepDown(args.Term)
+	}
+
+	reply.Term = rn.currentTerm
+	reply.VoteGranted = false
+
+	if args.Term < rn.currentTerm {
+		return
+	}
+	// Ученики не голосуют
+	if !rn.isVoter() {
+		return
+	}
+	if rn.votedFor != -1 && rn.votedFor != args.CandidateID {
+		return
+	}
+
+	lastLogIndex, lastLogTerm := rn.getLastLogInfo()
+	if args.LastLogTerm < lastLogTerm ||
+		(args.LastLogTerm == lastLogTerm && args.LastLogIndex < lastLogIndex) {
+		return
+	}
+
+	rn.votedFor = args.CandidateID
+	rn.persistState()
+	rn.resetElectionTimer()
+	reply.VoteGranted = true
 }
 
 func (rn *RaftNode) sendHeartbeats() {
@@ -132,6 +239,12 @@ func (rn *RaftNode) sendHeartbeats() {
 				rn.mu.Unlock()
 				return
 			}
+			if !rn.quorumContact(time.Now()) {
+				log.Printf("raft: no contact with a quorum for %v, stepping down", minElectionTimeout)
+				rn.becomeFollower()
+				rn.mu.Unlock()
+				return
+			}
 			rn.mu.Unlock()
 
 			rn.broadcastAppendEntries()
@@ -0,0 +1,90 @@
+package raft
+
+import "fmt"
+
+// FileStore — LogStore и StableStore поверх WAL. Записи дублируются в памяти,
+// на диск идёт только журнал изменений.
+type FileStore struct {
+	wal   *WAL
+	cache *InmemStore
+}
+
+func NewFileStore(dir string) (*FileStore, error) {
+	cache := NewInmemStore()
+
+	wal, err := OpenWAL(dir, func(rec walRecord) error {
+		switch rec.Type {
+		case walRecordEntry:
+			return cache.StoreLogs([]LogEntry{{
+				Index:   rec.Index,
+				Term:    rec.Term,
+				Command: rec.Command,
+			}})
+		case walRecordDelete:
+			return cache.DeleteRange(rec.Index, rec.MaxIndex)
+		case walRecordState:
+			return cache.SetState(rec.Term, rec.VotedFor)
+		default:
+			return fmt.Errorf("%w: unknown record type %d", ErrCorruptWAL, rec.Type)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &FileStore{wal: wal, cache: cache}, nil
+}
+
+func (s *FileStore) FirstIndex() (int, error) {
+	return s.cache.FirstIndex()
+}
+
+func (s *FileStore) LastIndex() (int, error) {
+	return s.cache.LastIndex()
+}
+
+func (s *FileStore) GetLog(index int, entry *LogEntry) error {
+	return s.cache.GetLog(index, entry)
+}
+
+func (s *FileStore) StoreLogs(entries []LogEntry) error {
+	if err := s.wal.AppendEntries(entries); err != nil {
+		return err
+	}
+	return s.cache.StoreLogs(entries)
+}
+
+func (s *FileStore) DeleteRange(min, max int) error {
+	first, _ := s.cache.FirstIndex()
+	if err := s.wal.DeleteRange(min, max); err != nil {
+		return err
+	}
+	if err := s.cache.DeleteRange(min, max); err != nil {
+		return err
+	}
+	// Обрезка начала лога после снапшота: старые сегменты больше не нужны
+	if min <= first {
+		term, votedFor, _ := s.cache.GetState()
+		return s.wal.Checkpoint(term, votedFor, max+1)
+	}
+	return nil
+}
+
+func (s *FileStore) GetState() (int, int, error) {
+	return s.cache.GetState()
+}
+
+func (s *FileStore) SetState(term, votedFor int) error {
+	if err := s.wal.SaveState(term, votedFor); err != nil {
+		return err
+	}
+	return s.cache.SetState(term, votedFor)
+}
+
+func (s *FileStore) AppendedBytes() int64 {
+	return s.wal.AppendedBytes()
+}
+
+func (s *FileStore) Close() error {
+	return s.wal.Close()
+}
@@ -0,0 +1,70 @@
+package raft
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log"
+)
+
+var ErrNoFSM = errors.New("raft: no FSM configured")
+
+// FSM — реплицируемый автомат, которым управляет RaftNode. Apply вызывается
+// для каждой закоммиченной записи строго по порядку индексов и из одной
+// горутины; его результат возвращается предложившему команду клиенту.
+type FSM interface {
+	Apply(entry LogEntry) interface{}
+	Snapshot() ([]byte, error)
+	Restore(r io.Reader) error
+}
+
+func (rn *RaftNode) SetFSM(fsm FSM) {
+	rn.fsmMu.Lock()
+	defer rn.fsmMu.Unlock()
+	rn.fsm = fsm
+}
+
+func (rn *RaftNode) runFSM() {
+	for {
+		select {
+		case msg := <-rn.applyCh:
+			rn.fsmMu.Lock()
+			switch {
+			case msg.SnapshotValid:
+				if err := rn.fsm.Restore(bytes.NewReader(msg.Snapshot)); err != nil {
+					log.Fatalf("raft: failed to restore snapshot %d: %v", msg.SnapshotIndex, err)
+				}
+				rn.fsmIndex = msg.SnapshotIndex
+			case msg.CommandValid:
+				response := rn.fsm.Apply(LogEntry{
+					Index:   msg.CommandIndex,
+					Term:    msg.CommandTerm,
+					Command: msg.Command,
+				})
+				rn.fsmIndex = msg.CommandIndex
+				rn.resolveFuture(msg.CommandIndex, msg.CommandTerm, response)
+			}
+			close(rn.appliedNotify)
+			rn.appliedNotify = make(chan struct{})
+			rn.fsmMu.Unlock()
+		case <-rn.stopCh:
+			return
+		}
+	}
+}
+
+// snapshotFSM возвращает состояние автомата вместе с индексом последней
+// применённой к нему записи.
+func (rn *RaftNode) snapshotFSM() (int, []byte, error) {
+	rn.fsmMu.Lock()
+	defer rn.fsmMu.Unlock()
+
+	if rn.fsm == nil {
+		return 0, nil, ErrNoFSM
+	}
+	data, err := rn.fsm.Snapshot()
+	if err != nil {
+		return 0, nil, err
+	}
+	return rn.fsmIndex, data, nil
+}
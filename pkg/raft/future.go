@@ -0,0 +1,91 @@
+package raft
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	ErrLeadershipLost  = errors.New("raft: leadership lost before the entry was applied, outcome unknown")
+	ErrProposalTimeout = errors.New("raft: timed out waiting for the entry to be applied, outcome unknown")
+)
+
+// ApplyFuture разрешается, когда предложенная запись закоммичена и применена
+// к FSM, либо когда лидер потерял лидерство.
+type ApplyFuture struct {
+	index    int
+	term     int
+	done     chan struct{}
+	response interface{}
+	err      error
+}
+
+func newApplyFuture(index, term int) *ApplyFuture {
+	return &ApplyFuture{
+		index: index,
+		term:  term,
+		done:  make(chan struct{}),
+	}
+}
+
+func failedFuture(err error) *ApplyFuture {
+	f := newApplyFuture(0, 0)
+	f.resolve(nil, err)
+	return f
+}
+
+func (f *ApplyFuture) Index() int {
+	return f.index
+}
+
+func (f *ApplyFuture) Wait(ctx context.Context) (interface{}, error) {
+	select {
+	case <-f.done:
+		return f.response, f.err
+	case <-ctx.Done():
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, ErrProposalTimeout
+		}
+		return nil, ctx.Err()
+	}
+}
+
+func (f *ApplyFuture) resolve(response interface{}, err error) {
+	f.response = response
+	f.err = err
+	close(f.done)
+}
+
+func (rn *RaftNode) trackFuture(f *ApplyFuture) {
+	rn.pendingMu.Lock()
+	defer rn.pendingMu.Unlock()
+	rn.pending[f.index] = f
+}
+
+// resolveFuture вызывается из runFSM после применения записи. Если на этом
+// индексе оказалась запись другого терма, исходная команда была затёрта.
+func (rn *RaftNode) resolveFuture(index, term int, response interface{}) {
+	rn.pendingMu.Lock()
+	f, ok := rn.pending[index]
+	delete(rn.pending, index)
+	rn.pendingMu.Unlock()
+
+	if !ok {
+		return
+	}
+	if f.term != term {
+		f.resolve(nil, ErrLeadershipLost)
+		return
+	}
+	f.resolve(response, nil)
+}
+
+func (rn *RaftNode) failPending(err error) {
+	rn.pendingMu.Lock()
+	defer rn.pendingMu.Unlock()
+
+	for index, f := range rn.pending {
+		f.resolve(nil, err)
+		delete(rn.pending, index)
+	}
+}
@@ -0,0 +1,90 @@
+package raft
+
+import "sync"
+
+type InmemStore struct {
+	mu        sync.RWMutex
+	lowIndex  int
+	highIndex int
+	logs      map[int]LogEntry
+	term      int
+	votedFor  int
+}
+
+func NewInmemStore() *InmemStore {
+	return &InmemStore{
+		logs:     make(map[int]LogEntry),
+		votedFor: -1,
+	}
+}
+
+func (s *InmemStore) FirstIndex() (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.lowIndex, nil
+}
+
+func (s *InmemStore) LastIndex() (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.highIndex, nil
+}
+
+func (s *InmemStore) GetLog(index int, entry *LogEntry) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	e, ok := s.logs[index]
+	if !ok {
+		return ErrLogNotFound
+	}
+	*entry = e
+	return nil
+}
+
+func (s *InmemStore) StoreLogs(entries []LogEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, e := range entries {
+		s.logs[e.Index] = e
+		if s.lowIndex == 0 || e.Index < s.lowIndex {
+			s.lowIndex = e.Index
+		}
+		if e.Index > s.highIndex {
+			s.highIndex = e.Index
+		}
+	}
+	return nil
+}
+
+func (s *InmemStore) DeleteRange(min, max int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := min; i <= max; i++ {
+		delete(s.logs, i)
+	}
+	if min <= s.lowIndex {
+		s.lowIndex = max + 1
+	}
+	if max >= s.highIndex {
+		s.highIndex = min - 1
+	}
+	if s.lowIndex > s.highIndex {
+		s.lowIndex = 0
+		s.highIndex = 0
+	}
+	return nil
+}
+
+func (s *InmemStore) GetState() (int, int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.term, s.votedFor, nil
+}
+
+func (s *InmemStore) SetState(term, votedFor int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.term = term
+	s.votedFor = votedFor
+	return nil
+}
@@ -0,0 +1,178 @@
+package raft
+
+import (
+	"fmt"
+	"log"
+
+	pb "raft-kv-store/proto"
+)
+
+func (rn *RaftNode) sendSnapshotToPeer(peerID int) {
+	rn.mu.Lock()
+	if rn.state != Leader || rn.installingSnapshot[peerID] {
+		rn.mu.Unlock()
+		return
+	}
+	rn.installingSnapshot[peerID] = true
+	term := rn.currentTerm
+	addr := rn.peers[peerID]
+	rn.mu.Unlock()
+
+	defer func() {
+		rn.mu.Lock()
+		delete(rn.installingSnapshot, peerID)
+		rn.mu.Unlock()
+	}()
+
+	meta, file, err := rn.snapshots.OpenLatest()
+	if err != nil {
+		log.Printf("raft: cannot send snapshot to %s: %v", addr, err)
+		return
+	}
+	defer file.Close()
+
+	args := &pb.InstallSnapshotRequest{
+		Term:              uint64(term),
+		LeaderId:          uint32(rn.id),
+		LastIncludedIndex: uint64(meta.Index),
+		LastIncludedTerm:  uint64(meta.Term),
+	}
+
+	reply, err := rn.sendInstallSnapshot(addr, args, file)
+	if err != nil {
+		log.Printf("raft: install snapshot on %s failed: %v", addr, err)
+		return
+	}
+
+	rn.mu.Lock()
+	defer rn.mu.Unlock()
+
+	if int(reply.Term) > rn.currentTerm {
+		rn.stepDown(int(reply.Term))
+		return
+	}
+	if rn.state != Leader || rn.currentTerm != term {
+		return
+	}
+	if _, ok := rn.peers[peerID]; !ok {
+		return
+	}
+
+	rn.nextIndex[peerID] = meta.Index + 1
+	rn.matchIndex[peerID] = meta.Index
+}
+
+func (rn *RaftNode) HandleInstallSnapshot(stream pb.RaftService_InstallSnapshotServer) error {
+	var sink *snapshotSink
+	defer func() {
+		if sink != nil {
+			sink.Cancel()
+		}
+	}()
+
+	for {
+		req, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+
+		rn.mu.Lock()
+		if int(req.Term) < rn.currentTerm {
+			term := rn.currentTerm
+			rn.mu.Unlock()
+			return stream.SendAndClose(&pb.InstallSnapshotResponse{Term: uint64(term)})
+		}
+		if int(req.Term) > rn.currentTerm {
+			rn.stepDown(int(req.Term))
+		}
+		rn.resetElectionTimer()
+		rn.mu.Unlock()
+
+		if sink == nil {
+			sink, err = rn.snapshots.CreateSink(int(req.LastIncludedIndex), int(req.LastIncludedTerm))
+			if err != nil {
+				return err
+			}
+		}
+
+		if int64(req.Offset) != sink.offset {
+			return fmt.Errorf("snapshot chunk at offset %d, expected %d", req.Offset, sink.offset)
+		}
+		if _, err := sink.Write(req.Data); err != nil {
+			return err
+		}
+
+		if !req.Done {
+			continue
+		}
+
+		snapshot, err := rn.snapshots.CommitSink(sink, req.Checksum)
+		sink = nil
+		if err == ErrStaleSnapshot {
+			// Состояние узла уже не старше присланного
+			rn.mu.Lock()
+			term := rn.currentTerm
+			rn.mu.Unlock()
+			return stream.SendAndClose(&pb.InstallSnapshotResponse{Term: uint64(term)})
+		}
+		if err != nil {
+			return err
+		}
+
+		rn.applyMu.Lock()
+		rn.mu.Lock()
+		msg, ok := rn.restoreFromSnapshot(*snapshot)
+		term := rn.currentTerm
+		rn.mu.Unlock()
+		if ok {
+			rn.sendApply(msg)
+		}
+		rn.applyMu.Unlock()
+
+		return stream.SendAndClose(&pb.InstallSnapshotResponse{Term: uint64(term)})
+	}
+}
+
+// restoreFromSnapshot вызывается под rn.mu либо до запуска узла. Сообщение
+// для автомата вызывающий отправляет через sendApply уже без rn.mu.
+func (rn *RaftNode) restoreFromSnapshot(snapshot Snapshot) (ApplyMsg, bool) {
+	if snapshot.LastIncludedIndex <= rn.lastSnapshotIndex {
+		return ApplyMsg{}, false
+	}
+
+	first, lastIndex := rn.firstLogIndex(), rn.lastLogIndex()
+	if snapshot.LastIncludedIndex <= lastIndex &&
+		snapshot.LastIncludedIndex >= first &&
+		rn.termAt(snapshot.LastIncludedIndex) == snapshot.LastIncludedTerm {
+		// Лог совпадает со снапшотом: как и после takeSnapshot, оставляем
+		// TrailingLogs записей до него и весь хвост после
+		if cut := snapshot.LastIncludedIndex - rn.snapshots.config.TrailingLogs; cut >= first {
+			rn.deleteLogs(first, cut)
+		}
+	} else if lastIndex >= first {
+		rn.deleteLogs(first, lastIndex)
+	}
+
+	rn.lastSnapshotIndex = snapshot.LastIncludedIndex
+	rn.lastSnapshotTerm = snapshot.LastIncludedTerm
+	rn.bytesSinceSnapshot = 0
+	// Снапшоты без конфигурации сделаны до появления смены состава
+	if len(snapshot.Configuration.Members) > 0 {
+		rn.restoreConfigs(snapshot.LastIncludedIndex, snapshot.Configuration)
+	}
+
+	if rn.commitIndex < snapshot.LastIncludedIndex {
+		rn.commitIndex = snapshot.LastIncludedIndex
+	}
+	if rn.lastApplied >= snapshot.LastIncludedIndex {
+		return ApplyMsg{}, false
+	}
+	rn.lastApplied = snapshot.LastIncludedIndex
+
+	return ApplyMsg{
+		SnapshotValid: true,
+		Snapshot:      snapshot.Data,
+		SnapshotIndex: snapshot.LastIncludedIndex,
+		SnapshotTerm:  snapshot.LastIncludedTerm,
+	}, true
+}
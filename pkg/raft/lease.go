@@ -0,0 +1,100 @@
+package raft
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+const DefaultLeaseClockDrift = 200 * time.Millisecond
+
+type LeaseStats struct {
+	Valid   uint64
+	Expired uint64
+}
+
+func (rn *RaftNode) SetLeaseClockDrift(drift time.Duration) {
+	rn.mu.Lock()
+	defer rn.mu.Unlock()
+	rn.leaseDrift = drift
+}
+
+// LeaseRead отвечает без сетевого раунда, пока лидер держит lease: кворум
+// подтвердил heartbeat-ы, отправленные не раньше чем
+// minElectionTimeout - leaseDrift назад. Иначе откатывается на ReadIndex.
+// Во время передачи лидерства lease не действует: цель TimeoutNow получает
+// голоса в обход hasLiveLeader и может стать лидером до истечения lease.
+// После неудачной передачи это верно ещё maxElectionTimeout с момента
+// TimeoutNow: цель могла получить его и начать выборы позже.
+func (rn *RaftNode) LeaseRead(ctx context.Context) (int, error) {
+	rn.mu.Lock()
+	if rn.state != Leader {
+		rn.mu.Unlock()
+		return 0, ErrNotLeader
+	}
+	if rn.termAt(rn.commitIndex) != rn.currentTerm {
+		rn.mu.Unlock()
+		return 0, ErrReadIndexNotReady
+	}
+	if rn.transferring || time.Since(rn.timeoutNowAt) < maxElectionTimeout {
+		rn.mu.Unlock()
+		return rn.ReadIndex(ctx)
+	}
+	if !rn.leaseValid(time.Now()) {
+		rn.leaseStats.Expired++
+		rn.mu.Unlock()
+		return rn.ReadIndex(ctx)
+	}
+
+	rn.leaseStats.Valid++
+	readIndex := rn.commitIndex
+	rn.mu.Unlock()
+
+	if err := rn.waitApplied(ctx, readIndex); err != nil {
+		return 0, err
+	}
+	return readIndex, nil
+}
+
+func (rn *RaftNode) leaseValid(now time.Time) bool {
+	lease := minElectionTimeout - rn.leaseDrift
+	if lease <= 0 {
+		return false
+	}
+
+	acks := make([]time.Time, 0, len(rn.peers)+1)
+	if rn.isVoter() {
+		acks = append(acks, now)
+	}
+	for peerID := range rn.peers {
+		if !rn.learners[peerID] {
+			acks = append(acks, rn.lastAck[peerID])
+		}
+	}
+	quorum := rn.quorumSize()
+	if len(acks) < quorum {
+		return false
+	}
+	sort.Slice(acks, func(i, j int) bool { return acks[i].After(acks[j]) })
+
+	return now.Sub(acks[quorum-1]) < lease
+}
+
+// hasLiveLeader сообщает, что узел сам лидер или получал AppendEntries от
+// лидера в пределах минимального таймаута выборов.
+func (rn *RaftNode) hasLiveLeader() bool {
+	if rn.state == Leader {
+		return true
+	}
+	return !rn.lastLeaderContact.IsZero() &&
+		time.Since(rn.lastLeaderContact) < minElectionTimeout
+}
+
+// LeaseReadStats возвращает счётчики lease-чтений с прошлого вызова.
+func (rn *RaftNode) LeaseReadStats() LeaseStats {
+	rn.mu.Lock()
+	defer rn.mu.Unlock()
+	stats := rn.leaseStats
+	rn.leaseStats = LeaseStats{}
+	return stats
+}
@@ -0,0 +1,97 @@
+package raft
+
+import (
+	"context"
+	"time"
+)
+
+// startPreVote спрашивает участников, проголосовали бы они за этот узел в
+// следующем терме. currentTerm при этом не растёт: узел, отрезанный от
+// кластера, не набирает кворум и после возвращения не сбивает лидера своим
+// термом. Настоящие выборы начинаются, только если кворум ответил «да».
+// Вызывается под rn.mu.
+func (rn *RaftNode) startPreVote() {
+	quorum := rn.quorumSize()
+	if quorum <= 1 {
+		rn.startNewElection(false)
+		return
+	}
+
+	term := rn.currentTerm
+	lastLogIndex, lastLogTerm := rn.getLastLogInfo()
+	args := RequestVoteArgs{
+		Term:         term + 1,
+		CandidateID:  rn.id,
+		LastLogIndex: lastLogIndex,
+		LastLogTerm:  lastLogTerm,
+	}
+
+	voteChan := make(chan bool, len(rn.peers))
+	voters := 0
+	for peerID, peer := range rn.peers {
+		if rn.learners[peerID] {
+			continue
+		}
+		voters++
+		go func(peer string) {
+			ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+			defer cancel()
+
+			var reply RequestVoteReply
+			if err := rn.sendRequestPreVoteRPC(ctx, peer, &args, &reply); err != nil {
+				voteChan <- false
+				return
+			}
+
+			rn.mu.Lock()
+			if reply.Term > rn.currentTerm {
+				rn.stepDown(reply.Term)
+			}
+			rn.mu.Unlock()
+			voteChan <- reply.VoteGranted
+		}(peer)
+	}
+
+	go rn.countPreVotes(voteChan, voters, quorum, term)
+}
+
+func (rn *RaftNode) countPreVotes(voteChan <-chan bool, peersCount, quorum, term int) {
+	votes := 1
+	for i := 0; i < peersCount; i++ {
+		if !<-voteChan {
+			continue
+		}
+		votes++
+		if votes < quorum {
+			continue
+		}
+
+		rn.mu.Lock()
+		if rn.state != Leader && rn.currentTerm == term && rn.isVoter() {
+			rn.startNewElection(false)
+		}
+		rn.mu.Unlock()
+		return
+	}
+}
+
+// HandleRequestPreVote отвечает, отдал бы узел голос кандидату, не меняя
+// ни терм, ни votedFor. Пока узел слышит живого лидера, он отказывает.
+func (rn *RaftNode) HandleRequestPreVote(args *RequestVoteArgs, reply *RequestVoteReply) {
+	rn.mu.Lock()
+	defer rn.mu.Unlock()
+
+	reply.Term = rn.currentTerm
+	reply.VoteGranted = false
+
+	if args.Term < rn.currentTerm || !rn.isVoter() || rn.hasLiveLeader() {
+		return
+	}
+
+	lastLogIndex, lastLogTerm := rn.getLastLogInfo()
+	if args.LastLogTerm < lastLogTerm ||
+		(args.LastLogTerm == lastLogTerm && args.LastLogIndex < lastLogIndex) {
+		return
+	}
+	reply.VoteGranted = true
+}
@@ -1,7 +1,20 @@
 package raft
 
 import (
+	"errors"
+	"path/filepath"
 	"sync"
+	"time"
+)
+
+var ErrNotLeader = errors.New("raft: not leader")
+
+type State int
+
+const (
+	Follower State = iota
+	Candidate
+	Leader
 )
 
 type RaftNode struct {
@@ -10,80 +23,145 @@ type RaftNode struct {
 	state       State
 	currentTerm int
 	votedFor    int
-	log         []LogEntry
 	commitIndex int
 	lastApplied int
-	peers       []string
+	peers       map[int]string
+	configs     []configEntry
+	learners    map[int]bool
+	promoteLag  int
+	rpcAddr     string
+	leaderAddr  string
+
+	logs   LogStore
+	stable StableStore
+
+	snapshots          *SnapshotStore
+	lastSnapshotIndex  int
+	lastSnapshotTerm   int
+	installingSnapshot map[int]bool
+	bytesSinceSnapshot int64
+	appendedBytes      int64
 
 	nextIndex  map[int]int
 	matchIndex map[int]int
 
-	applyCh chan ApplyMsg
-	rpcCh   chan RPC
-	stopCh  chan struct{}
+	fsm           FSM
+	fsmMu         sync.Mutex
+	fsmIndex      int
+	appliedNotify chan struct{}
+
+	readSeq      uint64
+	readRequests []*readRequest
+
+	lastAck     map[int]time.Time
+	leaderSince time.Time
+	leaseDrift  time.Duration
+	leaseStats  LeaseStats
+
+	lastLeaderContact time.Time
+	leaderCommit      int
+	commitSamples     []commitSample
+
+	pending   map[int]*ApplyFuture
+	pendingMu sync.Mutex
+
+	transferring bool
+	// timeoutNowAt — когда лидер последний раз отправил TimeoutNow
+	timeoutNowAt time.Time
+
+	rpcStats rpcStats
+
+	// applyMu берётся до rn.mu и держится, пока сообщения уходят в applyCh
+	applyMu      sync.Mutex
+	applyCh      chan ApplyMsg
+	resetTimerCh chan struct{}
+	stopCh       chan struct{}
+	stopOnce     sync.Once
 }
 
 type LogEntry struct {
+	Index   int
 	Term    int
 	Command interface{}
 }
 
-func (rn *RaftNode) Run() {
-	for {
-		select {
-		case <-rn.stopCh:
-			return
-		default:
-			switch rn.getState() {
-			case Follower:
-				rn.runFollower()
-			case Candidate:
-				rn.runCandidate()
-			case Leader:
-				rn.runLeader()
-			}
-		}
+type ApplyMsg struct {
+	CommandValid bool
+	Command      interface{}
+	CommandIndex int
+	CommandTerm  int
+
+	SnapshotValid bool
+	Snapshot      []byte
+	SnapshotIndex int
+	SnapshotTerm  int
+}
+
+func NewRaftNode(id int, peers []string) *RaftNode {
+	store := NewInmemStore()
+	rn, err := NewRaftNodeWithStores(id, peers, store, store)
+	if err != nil {
+		panic(err)
 	}
+	return rn
 }
 
-func (rn *RaftNode) runFollower() {
-	timeout := randomTimeout()
-	select {
-	case rpc := <-rn.rpcCh:
-		rn.handleRPC(rpc)
-	case <-timeout:
-		rn.setState(Candidate)
+func NewDurableRaftNode(id int, peers []string, dataDir string) (*RaftNode, error) {
+	store, err := NewFileStore(filepath.Join(dataDir, walDir))
+	if err != nil {
+		return nil, err
 	}
+	return NewRaftNodeWithStores(id, peers, store, store)
 }
 
-func (rn *RaftNode) startElection() {
-	rn.mu.Lock()
-	rn.currentTerm++
-	rn.votedFor = rn.id
-	votes := 1
+func NewRaftNodeWithStores(id int, peers []string, logs LogStore, stable StableStore) (*RaftNode, error) {
+	term, votedFor, err := stable.GetState()
+	if err != nil {
+		return nil, err
+	}
+
+	rn := &RaftNode{
+		id:           id,
+		state:        Follower,
+		currentTerm:  term,
+		votedFor:     votedFor,
+		configs:      []configEntry{{config: BootstrapConfiguration(id, peers)}},
+		logs:         logs,
+		stable:       stable,
+		nextIndex:    make(map[int]int),
+		matchIndex:   make(map[int]int),
+		applyCh:      make(chan ApplyMsg, 256),
+		resetTimerCh: make(chan struct{}, 1),
+		stopCh:       make(chan struct{}),
 
-	args := RequestVoteArgs{
-		Term:        rn.currentTerm,
-		CandidateId: rn.id,
+		installingSnapshot: make(map[int]bool),
+		pending:            make(map[int]*ApplyFuture),
+		appliedNotify:      make(chan struct{}),
+		lastAck:            make(map[int]time.Time),
+		leaseDrift:         DefaultLeaseClockDrift,
+		promoteLag:         DefaultLearnerPromoteLag,
 	}
+	rn.loadConfigs()
+	return rn, nil
+}
 
-	for _, peer := range rn.peers {
-		go func(peer string) {
-			var reply RequestVoteReply
-			if err := rpcCall(peer, "Raft.RequestVote", &args, &reply); err == nil {
-				rn.mu.Lock()
-				if reply.Term > rn.currentTerm {
-					rn.currentTerm = reply.Term
-					rn.setState(Follower)
-				} else if reply.VoteGranted {
-					votes++
-					if votes > len(rn.peers)/2 {
-						rn.setState(Leader)
-					}
-				}
-				rn.mu.Unlock()
-			}
-		}(peer)
+// Run запускает применение записей к FSM и таймер выборов и возвращается
+// после Stop.
+func (rn *RaftNode) Run() {
+	go rn.runFSM()
+	rn.runElectionTimer()
+}
+
+// Stop останавливает фоновые горутины узла. Повторный вызов ничего не делает.
+func (rn *RaftNode) Stop() {
+	rn.stopOnce.Do(func() { close(rn.stopCh) })
+}
+
+// resetElectionTimer откладывает выборы, когда узел слышит лидера или
+// отдал голос. Вызывается под rn.mu.
+func (rn *RaftNode) resetElectionTimer() {
+	select {
+	case rn.resetTimerCh <- struct{}{}:
+	default:
 	}
-	rn.mu.Unlock()
 }
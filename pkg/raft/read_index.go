@@ -0,0 +1,118 @@
+package raft
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	ErrReadIndexNotReady = errors.New("raft: leader has not committed an entry in its term yet")
+	ErrReadTimeout       = errors.New("raft: timed out confirming read index")
+	ErrNodeStopped       = errors.New("raft: node stopped")
+)
+
+// readRequest ждёт, пока кворум подтвердит лидерство heartbeat-ами,
+// отправленными после регистрации запроса.
+type readRequest struct {
+	seq  uint64
+	acks map[int]bool
+	done chan struct{}
+	err  error
+}
+
+// ReadIndex возвращает индекс, начиная с которого локальное чтение
+// линеаризуемо: лидерство подтверждено кворумом, а FSM применил все записи
+// до commitIndex на момент вызова.
+func (rn *RaftNode) ReadIndex(ctx context.Context) (int, error) {
+	rn.mu.Lock()
+	if rn.state != Leader {
+		rn.mu.Unlock()
+		return 0, ErrNotLeader
+	}
+	if rn.termAt(rn.commitIndex) != rn.currentTerm {
+		rn.mu.Unlock()
+		return 0, ErrReadIndexNotReady
+	}
+
+	readIndex := rn.commitIndex
+	rn.readSeq++
+	req := &readRequest{
+		seq:  rn.readSeq,
+		acks: make(map[int]bool),
+		done: make(chan struct{}),
+	}
+	if rn.quorumSize() <= 1 {
+		close(req.done)
+	} else {
+		rn.readRequests = append(rn.readRequests, req)
+	}
+	rn.mu.Unlock()
+
+	go rn.broadcastAppendEntries()
+
+	select {
+	case <-req.done:
+		if req.err != nil {
+			return 0, req.err
+		}
+	case <-ctx.Done():
+		return 0, ErrReadTimeout
+	case <-rn.stopCh:
+		return 0, ErrNodeStopped
+	}
+
+	if err := rn.waitApplied(ctx, readIndex); err != nil {
+		return 0, err
+	}
+	return readIndex, nil
+}
+
+// ackReadRequests засчитывает ответ peerID всем запросам, которые были
+// зарегистрированы до отправки этого AppendEntries.
+func (rn *RaftNode) ackReadRequests(peerID int, sentSeq uint64) {
+	remaining := rn.readRequests[:0]
+	for _, req := range rn.readRequests {
+		if req.seq <= sentSeq && !rn.learners[peerID] {
+			req.acks[peerID] = true
+		}
+		if len(req.acks)+1 >= rn.quorumSize() {
+			close(req.done)
+			continue
+		}
+		remaining = append(remaining, req)
+	}
+	rn.readRequests = remaining
+}
+
+func (rn *RaftNode) failReadRequests(err error) {
+	for _, req := range rn.readRequests {
+		req.err = err
+		close(req.done)
+	}
+	rn.readRequests = nil
+}
+
+func (rn *RaftNode) waitApplied(ctx context.Context, index int) error {
+	for {
+		rn.fsmMu.Lock()
+		applied := rn.fsmIndex
+		notify := rn.appliedNotify
+		rn.fsmMu.Unlock()
+
+		if applied >= index {
+			return nil
+		}
+
+		select {
+		case <-notify:
+		case <-ctx.Done():
+			return ErrReadTimeout
+		case <-rn.stopCh:
+			return ErrNodeStopped
+		}
+	}
+}
+
+func (rn *RaftNode) quorumSize() int {
+	return rn.configuration().voters()/2 + 1
+}
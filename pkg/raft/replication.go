@@ -1,10 +1,14 @@
 package raft
 
-import "sort"
+import (
+	"sort"
+	"time"
+)
 
 type AppendEntriesArgs struct {
 	Term         int
 	LeaderID     int
+	LeaderAddr   string
 	PrevLogIndex int
 	PrevLogTerm  int
 	Entries      []LogEntry
@@ -18,6 +22,30 @@ type AppendEntriesReply struct {
 	ConflictTerm  int
 }
 
+func (rn *RaftNode) Propose(command interface{}) *ApplyFuture {
+	rn.mu.Lock()
+	defer rn.mu.Unlock()
+
+	if rn.state != Leader {
+		return failedFuture(ErrNotLeader)
+	}
+	if rn.transferring {
+		return failedFuture(ErrTransferInProgress)
+	}
+
+	entry := LogEntry{
+		Index:   rn.lastLogIndex() + 1,
+		Term:    rn.currentTerm,
+		Command: command,
+	}
+	future := newApplyFuture(entry.Index, entry.Term)
+	rn.trackFuture(future)
+	rn.storeLogs([]LogEntry{entry})
+	// Единственный голосующий участник сам составляет кворум
+	rn.updateCommitIndex()
+	return future
+}
+
 func (rn *RaftNode) broadcastAppendEntries() {
 	rn.mu.Lock()
 	defer rn.mu.Unlock()
@@ -39,31 +67,33 @@ func (rn *RaftNode) replicateToPeer(peerID int) {
 	rn.mu.Lock()
 
 	nextIndex := rn.nextIndex[peerID]
-	prevLogIndex := nextIndex - 1
-	prevLogTerm := 0
-
-	if prevLogIndex >= 0 {
-		prevLogTerm = rn.log[prevLogIndex].Term
+	if rn.snapshots != nil && (nextIndex < rn.firstLogIndex() || !rn.hasTerm(nextIndex-1)) {
+		rn.mu.Unlock()
+		rn.sendSnapshotToPeer(peerID)
+		return
 	}
 
-	entries := make([]LogEntry, 0)
-	if nextIndex <= len(rn.log)-1 {
-		entries = rn.log[nextIndex:]
-	}
+	prevLogIndex := nextIndex - 1
+	prevLogTerm := rn.termAt(prevLogIndex)
+	entries := rn.entriesFrom(nextIndex)
 
 	args := AppendEntriesArgs{
 		Term:         rn.currentTerm,
 		LeaderID:     rn.id,
+		LeaderAddr:   rn.rpcAddr,
 		PrevLogIndex: prevLogIndex,
 		PrevLogTerm:  prevLogTerm,
 		Entries:      entries,
 		LeaderCommit: rn.commitIndex,
 	}
+	addr := rn.peers[peerID]
+	readSeq := rn.readSeq
 
 	rn.mu.Unlock()
 
+	sentAt := time.Now()
 	var reply AppendEntriesReply
-	err := rn.sendAppendEntries(peerID, &args, &reply)
+	err := rn.sendAppendEntries(addr, &args, &reply)
 	if err != nil {
 		return
 	}
@@ -75,6 +105,16 @@ func (rn *RaftNode) replicateToPeer(peerID int) {
 		rn.stepDown(reply.Term)
 		return
 	}
+	if rn.state != Leader || rn.currentTerm != args.Term {
+		return
+	}
+	if _, ok := rn.peers[peerID]; !ok {
+		return
+	}
+	// Ответ любого исхода подтверждает связь с фоловером: на lastAck
+	// опираются lease-чтения и CheckQuorum
+	rn.lastAck[peerID] = sentAt
+	rn.ackReadRequests(peerID, readSeq)
 
 	if !reply.Success {
 		if reply.ConflictTerm != 0 {
@@ -93,36 +133,68 @@ func (rn *RaftNode) replicateToPeer(peerID int) {
 	rn.nextIndex[peerID] = nextIndex + len(entries)
 	rn.matchIndex[peerID] = rn.nextIndex[peerID] - 1
 	rn.updateCommitIndex()
+	rn.maybePromote(peerID)
 }
 
+// updateCommitIndex считает кворум по голосующим участникам действующей
+// конфигурации; ученики и лидер, исключивший себя, в нём не участвуют.
 func (rn *RaftNode) updateCommitIndex() {
-	matchIndexes := make([]int, 0, len(rn.peers))
-	for _, mi := range rn.matchIndex {
-		matchIndexes = append(matchIndexes, mi)
+	matchIndexes := make([]int, 0, len(rn.peers)+1)
+	for peerID := range rn.peers {
+		if !rn.learners[peerID] {
+			matchIndexes = append(matchIndexes, rn.matchIndex[peerID])
+		}
+	}
+	if rn.isVoter() {
+		matchIndexes = append(matchIndexes, rn.lastLogIndex())
+	}
+	quorum := rn.quorumSize()
+	if len(matchIndexes) < quorum {
+		return
 	}
-	matchIndexes = append(matchIndexes, len(rn.log)-1)
 
-	sort.Ints(matchIndexes)
-	newCommitIndex := matchIndexes[len(matchIndexes)/2]
+	sort.Sort(sort.Reverse(sort.IntSlice(matchIndexes)))
+	newCommitIndex := matchIndexes[quorum-1]
 
 	if newCommitIndex > rn.commitIndex &&
-		rn.log[newCommitIndex].Term == rn.currentTerm {
+		rn.termAt(newCommitIndex) == rn.currentTerm {
 		rn.commitIndex = newCommitIndex
 		go rn.applyLogs()
+		rn.checkRemoved()
 	}
 }
 
+// applyLogs передаёт закоммиченные записи автомату. В applyCh пишем без
+// rn.mu, чтобы занятый автомат не останавливал узел; applyMu сохраняет
+// порядок сообщений между параллельными вызовами.
 func (rn *RaftNode) applyLogs() {
-	rn.mu.Lock()
-	defer rn.mu.Unlock()
+	rn.applyMu.Lock()
+	defer rn.applyMu.Unlock()
 
+	rn.mu.Lock()
+	var msgs []ApplyMsg
 	for rn.lastApplied < rn.commitIndex {
 		rn.lastApplied++
-		entry := rn.log[rn.lastApplied]
-		rn.applyCh <- ApplyMsg{
+		entry := rn.entryAt(rn.lastApplied)
+		msgs = append(msgs, ApplyMsg{
 			CommandValid: true,
 			Command:      entry.Command,
 			CommandIndex: rn.lastApplied,
+			CommandTerm:  entry.Term,
+		})
+	}
+	rn.mu.Unlock()
+
+	rn.sendApply(msgs...)
+}
+
+// sendApply вызывается под applyMu.
+func (rn *RaftNode) sendApply(msgs ...ApplyMsg) {
+	for _, msg := range msgs {
+		select {
+		case rn.applyCh <- msg:
+		case <-rn.stopCh:
+			return
 		}
 	}
 }
@@ -143,15 +215,24 @@ func (rn *RaftNode) HandleAppendEntries(args *AppendEntriesArgs, reply *AppendEn
 	if args.Term > rn.currentTerm {
 		rn.stepDown(args.Term)
 	}
+	rn.leaderAddr = args.LeaderAddr
+	rn.observeLeaderCommit(args.LeaderCommit)
 
-	if args.PrevLogIndex >= len(rn.log) ||
-		(args.PrevLogIndex >= 0 && rn.log[args.PrevLogIndex].Term != args.PrevLogTerm) {
+	if args.PrevLogIndex < rn.lastSnapshotIndex {
+		reply.ConflictIndex = rn.lastSnapshotIndex + 1
+		return
+	}
 
-		reply.ConflictIndex = len(rn.log)
-		if args.PrevLogIndex < len(rn.log) {
-			reply.ConflictTerm = rn.log[args.PrevLogIndex].Term
-			for i := args.PrevLogIndex - 1; i >= 0; i-- {
-				if rn.log[i].Term != reply.ConflictTerm {
+	lastIndex := rn.lastLogIndex()
+	if args.PrevLogIndex > lastIndex ||
+		rn.termAt(args.PrevLogIndex) != args.PrevLogTerm {
+
+		reply.ConflictIndex = lastIndex + 1
+		if args.PrevLogIndex <= lastIndex {
+			reply.ConflictTerm = rn.termAt(args.PrevLogIndex)
+			first := rn.firstLogIndex()
+			for i := args.PrevLogIndex - 1; i >= first; i-- {
+				if rn.termAt(i) != reply.ConflictTerm {
 					reply.ConflictIndex = i + 1
 					break
 				}
@@ -160,10 +241,20 @@ func (rn *RaftNode) HandleAppendEntries(args *AppendEntriesArgs, reply *AppendEn
 		return
 	}
 
-	rn.log = append(rn.log[:args.PrevLogIndex+1], args.Entries...)
+	for i, entry := range args.Entries {
+		if entry.Index <= lastIndex {
+			if rn.termAt(entry.Index) == entry.Term {
+				continue
+			}
+			rn.deleteLogs(entry.Index, lastIndex)
+			rn.truncateConfigs(entry.Index)
+		}
+		rn.storeLogs(args.Entries[i:])
+		break
+	}
 
 	if args.LeaderCommit > rn.commitIndex {
-		rn.commitIndex = min(args.LeaderCommit, len(rn.log)-1)
+		rn.commitIndex = min(args.LeaderCommit, rn.lastLogIndex())
 		go rn.applyLogs()
 	}
 
@@ -1,51 +1,23 @@
 package raft
 
 import (
+	"bytes"
 	"context"
-	"log"
-	"net"
+	"encoding/gob"
+	"hash/crc32"
+	"io"
 	"sync"
 	"time"
 
+	pb "raft-kv-store/proto"
+
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
 )
 
-type RaftServiceServer struct {
-	raftNode *RaftNode
-}
-
-func (s *RaftServiceServer) RequestVote(ctx context.Context, req *RequestVoteRequest) (*RequestVoteResponse, error) {
-	reply := &RequestVoteResponse{}
-	s.raftNode.HandleRequestVote(req, reply)
-	return reply, nil
-}
-
-func (s *RaftServiceServer) AppendEntries(ctx context.Context, req *AppendEntriesRequest) (*AppendEntriesResponse, error) {
-	reply := &AppendEntriesResponse{}
-	s.raftNode.HandleAppendEntries(req, reply)
-	return reply, nil
-}
-
-func (rn *RaftNode) startRPCServer() {
-	lis, err := net.Listen("tcp", rn.rpcAddr)
-	if err != nil {
-		log.Fatalf("failed to listen: %v", err)
-	}
-
-	grpcServer := grpc.NewServer()
-	RegisterRaftServiceServer(grpcServer, &RaftServiceServer{raftNode: rn})
-
-	go func() {
-		if err := grpcServer.Serve(lis); err != nil {
-			log.Fatalf("failed to serve: %v", err)
-		}
-	}()
-}
-
 type RaftClient struct {
 	conn   *grpc.ClientConn
-	client RaftServiceClient
+	client pb.RaftServiceClient
 }
 
 var clientCache = struct {
@@ -78,26 +50,181 @@ func getClient(addr string) (*RaftClient, error) {
 
 	client := &RaftClient{
 		conn:   conn,
-		client: NewRaftServiceClient(conn),
+		client: pb.NewRaftServiceClient(conn),
 	}
 
 	clientCache.clients[addr] = client
 	return client, nil
 }
 
-func (rn *RaftNode) sendRequestVote(peer string, args *RequestVoteRequest) (*RequestVoteResponse, error) {
+// RPCStats — число исходящих RPC и ошибок по типам с прошлого вызова
+// RaftNode.RPCStats.
+type RPCStats struct {
+	Requests map[string]uint64
+	Errors   map[string]uint64
+}
+
+type rpcStats struct {
+	mu       sync.Mutex
+	requests map[string]uint64
+	errors   map[string]uint64
+}
+
+func (s *rpcStats) record(typ string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.requests == nil {
+		s.requests = make(map[string]uint64)
+		s.errors = make(map[string]uint64)
+	}
+	s.requests[typ]++
+	if err != nil {
+		s.errors[typ]++
+	}
+}
+
+// RPCStats возвращает счётчики RPC и обнуляет их.
+func (rn *RaftNode) RPCStats() RPCStats {
+	rn.rpcStats.mu.Lock()
+	defer rn.rpcStats.mu.Unlock()
+	stats := RPCStats{Requests: rn.rpcStats.requests, Errors: rn.rpcStats.errors}
+	rn.rpcStats.requests = nil
+	rn.rpcStats.errors = nil
+	return stats
+}
+
+func (rn *RaftNode) sendRequestVoteRPC(ctx context.Context, peer string, args *RequestVoteArgs, reply *RequestVoteReply) (err error) {
+	defer func() { rn.rpcStats.record("RequestVote", err) }()
+
 	client, err := getClient(peer)
 	if err != nil {
-		return nil, err
+		return err
+	}
+
+	resp, err := client.client.RequestVote(ctx, &pb.RequestVoteRequest{
+		Term:               uint64(args.Term),
+		CandidateId:        uint32(args.CandidateID),
+		LastLogIndex:       uint64(args.LastLogIndex),
+		LastLogTerm:        uint64(args.LastLogTerm),
+		LeadershipTransfer: args.LeadershipTransfer,
+	})
+	if err != nil {
+		return err
+	}
+	reply.Term = int(resp.Term)
+	reply.VoteGranted = resp.VoteGranted
+	return nil
+}
+
+func (rn *RaftNode) sendRequestPreVoteRPC(ctx context.Context, peer string, args *RequestVoteArgs, reply *RequestVoteReply) (err error) {
+	defer func() { rn.rpcStats.record("RequestPreVote", err) }()
+
+	client, err := getClient(peer)
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.client.RequestPreVote(ctx, &pb.RequestVoteRequest{
+		Term:         uint64(args.Term),
+		CandidateId:  uint32(args.CandidateID),
+		LastLogIndex: uint64(args.LastLogIndex),
+		LastLogTerm:  uint64(args.LastLogTerm),
+	})
+	if err != nil {
+		return err
+	}
+	reply.Term = int(resp.Term)
+	reply.VoteGranted = resp.VoteGranted
+	return nil
+}
+
+func (rn *RaftNode) sendAppendEntries(peer string, args *AppendEntriesArgs, reply *AppendEntriesReply) (err error) {
+	defer func() { rn.rpcStats.record("AppendEntries", err) }()
+
+	client, err := getClient(peer)
+	if err != nil {
+		return err
+	}
+
+	req := &pb.AppendEntriesRequest{
+		Term:         uint64(args.Term),
+		LeaderId:     uint32(args.LeaderID),
+		LeaderAddr:   args.LeaderAddr,
+		PrevLogIndex: uint64(args.PrevLogIndex),
+		PrevLogTerm:  uint64(args.PrevLogTerm),
+		LeaderCommit: uint64(args.LeaderCommit),
+	}
+	for _, entry := range args.Entries {
+		command, err := encodeCommand(entry.Command)
+		if err != nil {
+			return err
+		}
+		req.Entries = append(req.Entries, &pb.LogEntry{Term: uint64(entry.Term), Command: command})
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
 	defer cancel()
 
-	return client.client.RequestVote(ctx, args)
+	resp, err := client.client.AppendEntries(ctx, req)
+	if err != nil {
+		return err
+	}
+	reply.Term = int(resp.Term)
+	reply.Success = resp.Success
+	reply.ConflictIndex = int(resp.ConflictIndex)
+	reply.ConflictTerm = int(resp.ConflictTerm)
+	return nil
+}
+
+// AppendEntriesFromProto разбирает запрос лидера. В proto у записей нет
+// индекса: они идут подряд сразу после PrevLogIndex.
+func AppendEntriesFromProto(req *pb.AppendEntriesRequest) (*AppendEntriesArgs, error) {
+	args := &AppendEntriesArgs{
+		Term:         int(req.Term),
+		LeaderID:     int(req.LeaderId),
+		LeaderAddr:   req.LeaderAddr,
+		PrevLogIndex: int(req.PrevLogIndex),
+		PrevLogTerm:  int(req.PrevLogTerm),
+		LeaderCommit: int(req.LeaderCommit),
+	}
+	for i, entry := range req.Entries {
+		command, err := decodeCommand(entry.Command)
+		if err != nil {
+			return nil, err
+		}
+		args.Entries = append(args.Entries, LogEntry{
+			Index:   args.PrevLogIndex + 1 + i,
+			Term:    int(entry.Term),
+			Command: command,
+		})
+	}
+	return args, nil
 }
 
-func (rn *RaftNode) sendAppendEntries(peer string, args *AppendEntriesRequest) (*AppendEntriesResponse, error) {
+// Команды передаются gob-кодированными, как и в WAL.
+func encodeCommand(command interface{}) ([]byte, error) {
+	if command == nil {
+		return nil, nil
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&command); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decodeCommand(data []byte) (interface{}, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	var command interface{}
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&command); err != nil {
+		return nil, err
+	}
+	return command, nil
+}
+
+func (rn *RaftNode) sendTimeoutNowRPC(peer string, args *pb.TimeoutNowRequest) (*pb.TimeoutNowResponse, error) {
 	client, err := getClient(peer)
 	if err != nil {
 		return nil, err
@@ -106,7 +233,64 @@ func (rn *RaftNode) sendAppendEntries(peer string, args *AppendEntriesRequest) (
 	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
 	defer cancel()
 
-	return client.client.AppendEntries(ctx, args)
+	return client.client.TimeoutNow(ctx, args)
+}
+
+const (
+	snapshotChunkSize  = 1 << 20
+	snapshotRPCTimeout = 2 * time.Minute
+)
+
+// sendInstallSnapshot передаёт файл снапшота частями до конца файла;
+// последняя часть, короче snapshotChunkSize, несёт Done и контрольную сумму.
+func (rn *RaftNode) sendInstallSnapshot(peer string, args *pb.InstallSnapshotRequest, data io.Reader) (*pb.InstallSnapshotResponse, error) {
+	client, err := getClient(peer)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), snapshotRPCTimeout)
+	defer cancel()
+
+	stream, err := client.client.InstallSnapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	crc := crc32.New(walCRCTable)
+	buf := make([]byte, snapshotChunkSize)
+	var offset int64
+
+	for {
+		n, err := io.ReadFull(data, buf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return nil, err
+		}
+		crc.Write(buf[:n])
+
+		chunk := &pb.InstallSnapshotRequest{
+			Term:              args.Term,
+			LeaderId:          args.LeaderId,
+			LastIncludedIndex: args.LastIncludedIndex,
+			LastIncludedTerm:  args.LastIncludedTerm,
+			Data:              buf[:n],
+			Offset:            uint64(offset),
+			Done:              err != nil,
+		}
+		offset += int64(n)
+		if chunk.Done {
+			chunk.Checksum = crc.Sum32()
+		}
+
+		if err := stream.Send(chunk); err != nil {
+			return nil, err
+		}
+		if chunk.Done {
+			break
+		}
+	}
+
+	return stream.CloseAndRecv()
 }
 
 func (rn *RaftNode) stopRPCServer() {
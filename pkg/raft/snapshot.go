@@ -0,0 +1,440 @@
+package raft
+
+import (
+	"compress/gzip"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+var ErrStaleSnapshot = errors.New("raft: snapshot is not newer than the latest one")
+
+const (
+	snapshotDir           = "snapshots"
+	snapshotCheckInterval = 5 * time.Second
+	retainSnapshots       = 3
+)
+
+// SnapshotConfig задаёт, когда узел делает снапшот и сколько записей
+// оставляет в логе после него для отстающих фолловеров. ThresholdBytes
+// считается по байтам, дописанным в WAL; хранилище в памяти его не учитывает.
+type SnapshotConfig struct {
+	Threshold      int
+	ThresholdBytes int64
+	TrailingLogs   int
+}
+
+func DefaultSnapshotConfig() SnapshotConfig {
+	return SnapshotConfig{
+		Threshold:      100000,
+		ThresholdBytes: 64 << 20,
+		TrailingLogs:   10240,
+	}
+}
+
+// Configuration — состав кластера на момент LastIncludedIndex: записи
+// конфигурации до него из лога удалены.
+type Snapshot struct {
+	LastIncludedIndex int
+	LastIncludedTerm  int
+	Configuration     Configuration
+	Data              []byte
+}
+
+type SnapshotMetadata struct {
+	Index int
+	Term  int
+	Size  int64
+	Time  time.Time
+	Path  string
+}
+
+type SnapshotStore struct {
+	mu        sync.RWMutex
+	dir       string
+	latest    *SnapshotMetadata
+	snapshots []SnapshotMetadata
+	compress  bool
+	config    SnapshotConfig
+	raftNode  *RaftNode
+}
+
+// NewSnapshotStore хранит снапшоты в dataDir рядом с WAL и восстанавливает
+// узел из последнего из них.
+func NewSnapshotStore(raftNode *RaftNode, dataDir string, compress bool, config SnapshotConfig) (*SnapshotStore, error) {
+	dir := filepath.Join(dataDir, snapshotDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	store := &SnapshotStore{
+		dir:      dir,
+		compress: compress,
+		config:   config,
+		raftNode: raftNode,
+	}
+
+	raftNode.snapshots = store
+	if err := store.loadLatestSnapshot(); err != nil {
+		return nil, err
+	}
+	go store.runCompaction()
+	return store, nil
+}
+
+func (s *SnapshotStore) CreateSnapshot(index int, term int, cfg Configuration, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.latest != nil && index <= s.latest.Index {
+		return ErrStaleSnapshot
+	}
+
+	snapshot := Snapshot{
+		LastIncludedIndex: index,
+		LastIncludedTerm:  term,
+		Configuration:     cfg,
+		Data:              data,
+	}
+
+	// Снапшот пишется во временный файл и появляется под своим именем только
+	// целиком и на диске: после него лог обрезается
+	file, err := os.CreateTemp(s.dir, "snapshot-*.snap.tmp")
+	if err != nil {
+		return err
+	}
+	size, err := s.writeSnapshot(file, snapshot)
+	if err != nil {
+		os.Remove(file.Name())
+		return err
+	}
+
+	filename := s.snapshotPath(index, term)
+	if err := os.Rename(file.Name(), filename); err != nil {
+		os.Remove(file.Name())
+		return err
+	}
+	if err := syncDir(s.dir); err != nil {
+		return err
+	}
+
+	metadata := SnapshotMetadata{
+		Index: index,
+		Term:  term,
+		Size:  size,
+		Time:  time.Now(),
+		Path:  filename,
+	}
+
+	s.snapshots = append(s.snapshots, metadata)
+	s.latest = &metadata
+
+	// Очистка старых снапшотов
+	s.cleanupOldSnapshots()
+	return nil
+}
+
+// writeSnapshot кодирует снапшот в file, сбрасывает его на диск и закрывает.
+// Размер файла известен только после сброса буфера gzip.
+func (s *SnapshotStore) writeSnapshot(file *os.File, snapshot Snapshot) (int64, error) {
+	defer file.Close()
+
+	var writer io.Writer = file
+	var gz *gzip.Writer
+	if s.compress {
+		gz = gzip.NewWriter(file)
+		writer = gz
+	}
+	if err := gob.NewEncoder(writer).Encode(snapshot); err != nil {
+		return 0, err
+	}
+	if gz != nil {
+		if err := gz.Close(); err != nil {
+			return 0, err
+		}
+	}
+	if err := file.Sync(); err != nil {
+		return 0, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), file.Close()
+}
+
+// snapshotPath строит имя файла из индекса и терма; нули слева сохраняют
+// порядок снапшотов при сортировке имён.
+func (s *SnapshotStore) snapshotPath(index, term int) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%020d-%020d.snap", index, term))
+}
+
+// loadLatestSnapshot заполняет список снапшотов по содержимому каталога,
+// удаляет недописанные временные файлы и восстанавливает узел из последнего
+// снапшота.
+func (s *SnapshotStore) loadLatestSnapshot() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tmpFiles, err := filepath.Glob(filepath.Join(s.dir, "*.snap.tmp"))
+	if err != nil {
+		return err
+	}
+	for _, path := range tmpFiles {
+		os.Remove(path)
+	}
+
+	// Glob сортирует имена, а значит, и снапшоты по индексу
+	files, err := filepath.Glob(filepath.Join(s.dir, "*.snap"))
+	if err != nil {
+		return err
+	}
+	for _, path := range files {
+		var index, term int
+		if _, err := fmt.Sscanf(filepath.Base(path), "%d-%d.snap", &index, &term); err != nil {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		s.snapshots = append(s.snapshots, SnapshotMetadata{
+			Index: index,
+			Term:  term,
+			Size:  info.Size(),
+			Time:  info.ModTime(),
+			Path:  path,
+		})
+	}
+
+	if len(s.snapshots) == 0 {
+		return nil
+	}
+
+	// Загрузка последнего снапшота
+	latest := s.snapshots[len(s.snapshots)-1]
+	snapshot, err := s.readSnapshotFile(latest.Path)
+	if err != nil {
+		return err
+	}
+	s.latest = &latest
+	s.cleanupOldSnapshots()
+
+	// Восстановление состояния
+	// Узел ещё не запущен, сообщение ляжет в буфер applyCh
+	if msg, ok := s.raftNode.restoreFromSnapshot(*snapshot); ok {
+		s.raftNode.sendApply(msg)
+	}
+	return nil
+}
+
+func (s *SnapshotStore) GetSnapshot(index int) (*Snapshot, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, meta := range s.snapshots {
+		if meta.Index == index {
+			file, err := os.Open(meta.Path)
+			if err != nil {
+				return nil, err
+			}
+			defer file.Close()
+
+			var reader io.Reader = file
+			if s.compress {
+				gz, err := gzip.NewReader(file)
+				if err != nil {
+					return nil, err
+				}
+				defer gz.Close()
+				reader = gz
+			}
+
+			var snapshot Snapshot
+			dec := gob.NewDecoder(reader)
+			if err := dec.Decode(&snapshot); err != nil {
+				return nil, err
+			}
+
+			return &snapshot, nil
+		}
+	}
+
+	return nil, errors.New("snapshot not found")
+}
+
+func (s *SnapshotStore) OpenLatest() (*SnapshotMetadata, *os.File, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.latest == nil {
+		return nil, nil, errors.New("no snapshot available")
+	}
+
+	file, err := os.Open(s.latest.Path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	meta := *s.latest
+	return &meta, file, nil
+}
+
+// snapshotSink собирает снапшот, принятый от лидера по частям, во временный файл.
+type snapshotSink struct {
+	file   *os.File
+	crc    hash.Hash32
+	offset int64
+	index  int
+	term   int
+}
+
+func (s *SnapshotStore) CreateSink(index, term int) (*snapshotSink, error) {
+	file, err := os.CreateTemp(s.dir, "install-*.snap.tmp")
+	if err != nil {
+		return nil, err
+	}
+
+	return &snapshotSink{
+		file:  file,
+		crc:   crc32.New(walCRCTable),
+		index: index,
+		term:  term,
+	}, nil
+}
+
+func (sink *snapshotSink) Write(p []byte) (int, error) {
+	n, err := sink.file.Write(p)
+	sink.crc.Write(p[:n])
+	sink.offset += int64(n)
+	return n, err
+}
+
+func (sink *snapshotSink) Cancel() {
+	sink.file.Close()
+	os.Remove(sink.file.Name())
+}
+
+func (s *SnapshotStore) CommitSink(sink *snapshotSink, checksum uint32) (*Snapshot, error) {
+	if sink.crc.Sum32() != checksum {
+		sink.Cancel()
+		return nil, fmt.Errorf("snapshot checksum mismatch: got %08x, want %08x",
+			sink.crc.Sum32(), checksum)
+	}
+
+	if err := sink.file.Sync(); err != nil {
+		sink.Cancel()
+		return nil, err
+	}
+	if err := sink.file.Close(); err != nil {
+		os.Remove(sink.file.Name())
+		return nil, err
+	}
+
+	snapshot, err := s.readSnapshotFile(sink.file.Name())
+	if err != nil {
+		os.Remove(sink.file.Name())
+		return nil, err
+	}
+	if snapshot.LastIncludedIndex != sink.index || snapshot.LastIncludedTerm != sink.term {
+		os.Remove(sink.file.Name())
+		return nil, errors.New("snapshot metadata does not match request")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Пока снапшот принимался, узел мог сделать свой, не старше этого
+	if s.latest != nil && sink.index <= s.latest.Index {
+		os.Remove(sink.file.Name())
+		return nil, ErrStaleSnapshot
+	}
+
+	now := time.Now()
+	filename := s.snapshotPath(sink.index, sink.term)
+	if err := os.Rename(sink.file.Name(), filename); err != nil {
+		os.Remove(sink.file.Name())
+		return nil, err
+	}
+	if err := syncDir(s.dir); err != nil {
+		return nil, err
+	}
+
+	metadata := SnapshotMetadata{
+		Index: sink.index,
+		Term:  sink.term,
+		Size:  sink.offset,
+		Time:  now,
+		Path:  filename,
+	}
+	s.snapshots = append(s.snapshots, metadata)
+	s.latest = &metadata
+	s.cleanupOldSnapshots()
+
+	return snapshot, nil
+}
+
+func (s *SnapshotStore) readSnapshotFile(path string) (*Snapshot, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var reader io.Reader = file
+	if s.compress {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		reader = gz
+	}
+
+	var snapshot Snapshot
+	if err := gob.NewDecoder(reader).Decode(&snapshot); err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
+
+func (s *SnapshotStore) cleanupOldSnapshots() {
+	if len(s.snapshots) <= retainSnapshots {
+		return
+	}
+
+	toDelete := len(s.snapshots) - retainSnapshots
+	for _, meta := range s.snapshots[:toDelete] {
+		os.Remove(meta.Path)
+	}
+
+	s.snapshots = s.snapshots[toDelete:]
+}
+
+func (s *SnapshotStore) runCompaction() {
+	ticker := time.NewTicker(snapshotCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if !s.raftNode.shouldSnapshot(s.config) {
+				continue
+			}
+			if err := s.raftNode.takeSnapshot(s.config.TrailingLogs); err != nil {
+				log.Printf("raft: snapshot failed: %v", err)
+			}
+		case <-s.raftNode.stopCh:
+			return
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package raft
+
+import (
+	"errors"
+	"time"
+)
+
+const maxCommitSamples = 64
+
+var ErrTooStale = errors.New("raft: local state is too far behind the leader")
+
+type commitSample struct {
+	index int
+	seen  time.Time
+}
+
+// observeLeaderCommit вызывается под rn.mu на каждом принятом AppendEntries.
+func (rn *RaftNode) observeLeaderCommit(leaderCommit int) {
+	now := time.Now()
+	rn.lastLeaderContact = now
+
+	if leaderCommit <= rn.leaderCommit {
+		return
+	}
+	rn.leaderCommit = leaderCommit
+	rn.commitSamples = append(rn.commitSamples, commitSample{index: leaderCommit, seen: now})
+	if len(rn.commitSamples) > maxCommitSamples {
+		rn.commitSamples = rn.commitSamples[len(rn.commitSamples)-maxCommitSamples:]
+	}
+}
+
+// StaleRead проверяет, что локальное состояние отстаёт от последнего
+// известного commitIndex лидера не больше чем на maxLagEntries записей
+// (если > 0) и не больше чем на maxLagTime (если > 0).
+func (rn *RaftNode) StaleRead(maxLagEntries int, maxLagTime time.Duration) error {
+	rn.mu.Lock()
+	defer rn.mu.Unlock()
+
+	if rn.state == Leader {
+		return nil
+	}
+
+	rn.fsmMu.Lock()
+	applied := rn.fsmIndex
+	rn.fsmMu.Unlock()
+
+	if rn.lastLeaderContact.IsZero() {
+		return ErrTooStale
+	}
+
+	if maxLagEntries > 0 && rn.leaderCommit-applied > maxLagEntries {
+		return ErrTooStale
+	}
+
+	if maxLagTime > 0 && rn.staleness(applied) > maxLagTime {
+		return ErrTooStale
+	}
+	return nil
+}
+
+// staleness — сколько времени локальное состояние не видело коммитов
+// лидера: с момента, когда узнали о первом ещё не применённом коммите,
+// либо с последнего контакта с лидером, если всё применено.
+func (rn *RaftNode) staleness(applied int) time.Duration {
+	remaining := rn.commitSamples[:0]
+	for _, sample := range rn.commitSamples {
+		if sample.index > applied {
+			remaining = append(remaining, sample)
+		}
+	}
+	rn.commitSamples = remaining
+
+	if len(remaining) > 0 {
+		return time.Since(remaining[0].seen)
+	}
+	return time.Since(rn.lastLeaderContact)
+}
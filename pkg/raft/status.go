@@ -0,0 +1,147 @@
+package raft
+
+// NodeStatus.Lag — на сколько записей участник отстаёт от commitIndex.
+// Отставание других участников известно только лидеру.
+type NodeStatus struct {
+	ID       int    `json:"id"`
+	Address  string `json:"address"`
+	Role     string `json:"role"`
+	Term     int    `json:"term"`
+	IsLeader bool   `json:"is_leader"`
+	Lag      int    `json:"lag"`
+}
+
+type ClusterStatus struct {
+	Leader      string       `json:"leader"`
+	Term        int          `json:"term"`
+	CommitIndex int          `json:"commit_index"`
+	Nodes       []NodeStatus `json:"nodes"`
+}
+
+func (rn *RaftNode) SetRPCAddr(addr string) {
+	rn.mu.Lock()
+	defer rn.mu.Unlock()
+	rn.rpcAddr = addr
+	// В начальной конфигурации свой адрес неизвестен до вызова SetRPCAddr
+	for i, m := range rn.configs[0].config.Members {
+		if m.ID == rn.id && m.Address == "" {
+			rn.configs[0].config.Members[i].Address = addr
+		}
+	}
+}
+
+// GetLeader возвращает gRPC-адрес текущего лидера, если он известен.
+func (rn *RaftNode) GetLeader() string {
+	rn.mu.Lock()
+	defer rn.mu.Unlock()
+	if rn.state == Leader {
+		return rn.rpcAddr
+	}
+	return rn.leaderAddr
+}
+
+func (rn *RaftNode) IsLeader() bool {
+	rn.mu.Lock()
+	defer rn.mu.Unlock()
+	return rn.state == Leader
+}
+
+func (rn *RaftNode) GetClusterStatus() ClusterStatus {
+	rn.mu.Lock()
+	defer rn.mu.Unlock()
+
+	leader := rn.leaderAddr
+	if rn.state == Leader {
+		leader = rn.rpcAddr
+	}
+
+	status := ClusterStatus{
+		Leader:      leader,
+		Term:        rn.currentTerm,
+		CommitIndex: rn.commitIndex,
+	}
+	for _, m := range rn.configuration().Members {
+		if m.ID == rn.id {
+			role := roleName(rn.state)
+			if m.Learner {
+				role = "learner"
+			}
+			status.Nodes = append(status.Nodes, NodeStatus{
+				ID:       rn.id,
+				Address:  rn.rpcAddr,
+				Role:     role,
+				Term:     rn.currentTerm,
+				IsLeader: rn.state == Leader,
+				Lag:      max(0, max(rn.commitIndex, rn.leaderCommit)-rn.lastApplied),
+			})
+			continue
+		}
+
+		role := "follower"
+		switch {
+		case m.Learner:
+			role = "learner"
+		case m.Address == leader:
+			role = "leader"
+		}
+		node := NodeStatus{
+			ID:       m.ID,
+			Address:  m.Address,
+			Role:     role,
+			IsLeader: m.Address == leader,
+		}
+		if rn.state == Leader {
+			node.Lag = max(0, rn.commitIndex-rn.matchIndex[m.ID])
+		}
+		status.Nodes = append(status.Nodes, node)
+	}
+	return status
+}
+
+func (rn *RaftNode) ID() int {
+	return rn.id
+}
+
+func (rn *RaftNode) StopCh() <-chan struct{} {
+	return rn.stopCh
+}
+
+func (rn *RaftNode) State() State {
+	rn.mu.Lock()
+	defer rn.mu.Unlock()
+	return rn.state
+}
+
+func (rn *RaftNode) CurrentTerm() int {
+	rn.mu.Lock()
+	defer rn.mu.Unlock()
+	return rn.currentTerm
+}
+
+func (rn *RaftNode) CommitIndex() int {
+	rn.mu.Lock()
+	defer rn.mu.Unlock()
+	return rn.commitIndex
+}
+
+// LogSize — число записей в логе.
+func (rn *RaftNode) LogSize() int {
+	rn.mu.Lock()
+	defer rn.mu.Unlock()
+	first := rn.firstLogIndex()
+	if first == 0 {
+		return 0
+	}
+	return rn.lastLogIndex() - first + 1
+}
+
+func roleName(state State) string {
+	switch state {
+	case Leader:
+		return "leader"
+	case Candidate:
+		return "candidate"
+	default:
+		return "follower"
+	}
+}
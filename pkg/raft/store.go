@@ -0,0 +1,143 @@
+package raft
+
+import (
+	"errors"
+	"log"
+)
+
+var ErrLogNotFound = errors.New("raft: log entry not found")
+
+// LogStore хранит записи лога по их raft-индексу. Пустой лог возвращает 0
+// из FirstIndex и LastIndex.
+type LogStore interface {
+	FirstIndex() (int, error)
+	LastIndex() (int, error)
+	GetLog(index int, entry *LogEntry) error
+	StoreLogs(entries []LogEntry) error
+	DeleteRange(min, max int) error
+}
+
+// StableStore хранит currentTerm и votedFor. SetState должен быть
+// durable к моменту возврата.
+type StableStore interface {
+	GetState() (term int, votedFor int, err error)
+	SetState(term int, votedFor int) error
+}
+
+// byteCounter реализуют хранилища, знающие объём дописанного на диск лога.
+// По нему считается SnapshotConfig.ThresholdBytes.
+type byteCounter interface {
+	AppendedBytes() int64
+}
+
+// firstLogIndex и lastLogIndex учитывают записи, ушедшие в снапшот:
+// при пустом LogStore лог начинается сразу после lastSnapshotIndex.
+func (rn *RaftNode) firstLogIndex() int {
+	index, err := rn.logs.FirstIndex()
+	if err != nil {
+		log.Fatalf("raft: failed to read first index: %v", err)
+	}
+	if index == 0 {
+		return rn.lastSnapshotIndex + 1
+	}
+	return index
+}
+
+func (rn *RaftNode) lastLogIndex() int {
+	index, err := rn.logs.LastIndex()
+	if err != nil {
+		log.Fatalf("raft: failed to read last index: %v", err)
+	}
+	if index < rn.lastSnapshotIndex {
+		return rn.lastSnapshotIndex
+	}
+	return index
+}
+
+func (rn *RaftNode) entryAt(index int) LogEntry {
+	var entry LogEntry
+	if err := rn.logs.GetLog(index, &entry); err != nil {
+		log.Fatalf("raft: failed to read log entry %d: %v", index, err)
+	}
+	return entry
+}
+
+func (rn *RaftNode) termAt(index int) int {
+	if index == 0 {
+		return 0
+	}
+	if index == rn.lastSnapshotIndex {
+		return rn.lastSnapshotTerm
+	}
+	return rn.entryAt(index).Term
+}
+
+// hasTerm сообщает, известен ли терм записи index: она есть в логе либо
+// это последняя запись снапшота. После снапшота с хвостом лог начинается
+// раньше lastSnapshotIndex, и запись перед его началом уже не известна.
+func (rn *RaftNode) hasTerm(index int) bool {
+	if index == 0 || index == rn.lastSnapshotIndex {
+		return true
+	}
+	return index >= rn.firstLogIndex() && index <= rn.lastLogIndex()
+}
+
+func (rn *RaftNode) entriesFrom(index int) []LogEntry {
+	lastIndex := rn.lastLogIndex()
+	if index > lastIndex {
+		return nil
+	}
+
+	entries := make([]LogEntry, 0, lastIndex-index+1)
+	for i := index; i <= lastIndex; i++ {
+		entries = append(entries, rn.entryAt(i))
+	}
+	return entries
+}
+
+func (rn *RaftNode) getLastLogInfo() (int, int) {
+	lastIndex := rn.lastLogIndex()
+	return lastIndex, rn.termAt(lastIndex)
+}
+
+func (rn *RaftNode) findLastIndexForTerm(term int) int {
+	first := rn.firstLogIndex()
+	for i := rn.lastLogIndex(); i >= first && i > 0; i-- {
+		t := rn.termAt(i)
+		if t == term {
+			return i
+		}
+		if t < term {
+			break
+		}
+	}
+	return -1
+}
+
+func (rn *RaftNode) storeLogs(entries []LogEntry) {
+	if len(entries) == 0 {
+		return
+	}
+	if err := rn.logs.StoreLogs(entries); err != nil {
+		log.Fatalf("raft: failed to store logs: %v", err)
+	}
+	if counter, ok := rn.logs.(byteCounter); ok {
+		appended := counter.AppendedBytes()
+		rn.bytesSinceSnapshot += appended - rn.appendedBytes
+		rn.appendedBytes = appended
+	}
+	rn.observeConfigs(entries)
+}
+
+func (rn *RaftNode) deleteLogs(min, max int) {
+	if err := rn.logs.DeleteRange(min, max); err != nil {
+		log.Fatalf("raft: failed to delete logs [%d, %d]: %v", min, max, err)
+	}
+}
+
+// persistState вызывается под rn.mu до ответа на RPC.
+func (rn *RaftNode) persistState() {
+	if err := rn.stable.SetState(rn.currentTerm, rn.votedFor); err != nil {
+		log.Fatalf("raft: failed to persist state: %v", err)
+	}
+}
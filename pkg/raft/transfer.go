@@ -0,0 +1,155 @@
+package raft
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	pb "raft-kv-store/proto"
+)
+
+const transferPollInterval = 20 * time.Millisecond
+
+var (
+	ErrTransferInProgress = errors.New("raft: leadership transfer in progress")
+	ErrTransferTimeout    = errors.New("raft: leadership transfer timed out")
+	ErrNoTransferTarget   = errors.New("raft: no voter to transfer leadership to")
+)
+
+// TransferLeadership передаёт лидерство участнику to, а при to == 0 —
+// голосующему участнику с наибольшим matchIndex. На время передачи лидер
+// не принимает новые записи, догоняет цель и отправляет ей TimeoutNow,
+// чтобы она начала выборы, не дожидаясь таймаута. Если к отмене ctx
+// лидерство не перешло, лидер снова принимает записи.
+func (rn *RaftNode) TransferLeadership(ctx context.Context, to int) error {
+	rn.mu.Lock()
+	if rn.state != Leader {
+		rn.mu.Unlock()
+		return ErrNotLeader
+	}
+	if rn.transferring {
+		rn.mu.Unlock()
+		return ErrTransferInProgress
+	}
+	if to == 0 {
+		to = rn.transferTarget()
+	}
+	if to == 0 {
+		rn.mu.Unlock()
+		return ErrNoTransferTarget
+	}
+	if _, ok := rn.peers[to]; !ok || rn.learners[to] {
+		rn.mu.Unlock()
+		return ErrMemberNotFound
+	}
+	rn.transferring = true
+	term := rn.currentTerm
+	rn.mu.Unlock()
+
+	defer func() {
+		rn.mu.Lock()
+		rn.transferring = false
+		rn.mu.Unlock()
+	}()
+
+	// Недоступная цель отвечает ошибкой сразу, поэтому попытки догнать её
+	// идут не чаще transferPollInterval
+	ticker := time.NewTicker(transferPollInterval)
+	defer ticker.Stop()
+
+	for {
+		rn.mu.Lock()
+		if rn.state != Leader || rn.currentTerm != term {
+			rn.mu.Unlock()
+			return ErrLeadershipLost
+		}
+		caughtUp := rn.matchIndex[to] == rn.lastLogIndex()
+		addr := rn.peers[to]
+		if caughtUp {
+			rn.timeoutNowAt = time.Now()
+		}
+		rn.mu.Unlock()
+
+		if caughtUp {
+			if err := rn.sendTimeoutNow(addr, term); err != nil {
+				return err
+			}
+			break
+		}
+		rn.replicateToPeer(to)
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ErrTransferTimeout
+		case <-rn.stopCh:
+			return ErrNodeStopped
+		}
+	}
+
+	for {
+		rn.mu.Lock()
+		done := rn.state != Leader || rn.currentTerm != term
+		rn.mu.Unlock()
+		if done {
+			return nil
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ErrTransferTimeout
+		case <-rn.stopCh:
+			return ErrNodeStopped
+		}
+	}
+}
+
+// transferTarget выбирает голосующего участника с самым длинным логом.
+func (rn *RaftNode) transferTarget() int {
+	target, best := 0, -1
+	for peerID := range rn.peers {
+		if rn.learners[peerID] {
+			continue
+		}
+		if match := rn.matchIndex[peerID]; match > best {
+			target, best = peerID, match
+		}
+	}
+	return target
+}
+
+func (rn *RaftNode) sendTimeoutNow(addr string, term int) error {
+	reply, err := rn.sendTimeoutNowRPC(addr, &pb.TimeoutNowRequest{
+		Term:     uint64(term),
+		LeaderId: uint32(rn.id),
+	})
+	if err != nil {
+		return err
+	}
+
+	rn.mu.Lock()
+	defer rn.mu.Unlock()
+	if int(reply.Term) > rn.currentTerm {
+		rn.stepDown(int(reply.Term))
+	}
+	return nil
+}
+
+// HandleTimeoutNow начинает выборы немедленно: лидер уже убедился, что лог
+// этого узла не короче его собственного.
+func (rn *RaftNode) HandleTimeoutNow(req *pb.TimeoutNowRequest) *pb.TimeoutNowResponse {
+	rn.mu.Lock()
+	defer rn.mu.Unlock()
+
+	if int(req.Term) < rn.currentTerm {
+		return &pb.TimeoutNowResponse{Term: uint64(rn.currentTerm)}
+	}
+	if int(req.Term) > rn.currentTerm {
+		rn.stepDown(int(req.Term))
+	}
+	if rn.isVoter() && rn.state != Leader {
+		rn.startNewElection(true)
+	}
+	return &pb.TimeoutNowResponse{Term: uint64(rn.currentTerm)}
+}
@@ -0,0 +1,369 @@
+package raft
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+const (
+	walDir         = "wal"
+	walSegmentSize = 64 << 20
+	walHeaderSize  = 8
+)
+
+const (
+	walRecordEntry byte = iota + 1
+	walRecordState
+	walRecordDelete
+)
+
+var (
+	ErrCorruptWAL     = errors.New("wal: corrupt record")
+	ErrRecordTooLarge = errors.New("wal: record exceeds segment size")
+
+	walCRCTable = crc32.MakeTable(crc32.Castagnoli)
+)
+
+type walRecord struct {
+	Type     byte
+	Index    int
+	MaxIndex int
+	Term     int
+	VotedFor int
+	Command  interface{}
+}
+
+type WAL struct {
+	mu      sync.Mutex
+	dir     string
+	segment *os.File
+	segSeq  int
+	segSize int64
+	// Наибольший индекс записи лога в каждом сегменте
+	segMaxIndex map[int]int
+	// Байты записей лога, дописанные с момента открытия
+	appended int64
+}
+
+func OpenWAL(dir string, replay func(walRecord) error) (*WAL, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	segments, err := listSegments(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &WAL{dir: dir, segMaxIndex: make(map[int]int)}
+	for i, seq := range segments {
+		last := i == len(segments)-1
+		err := replaySegment(segmentPath(dir, seq), last, func(rec walRecord) error {
+			if rec.Type == walRecordEntry && rec.Index > w.segMaxIndex[seq] {
+				w.segMaxIndex[seq] = rec.Index
+			}
+			return replay(rec)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(segments) == 0 {
+		if err := w.openSegment(0); err != nil {
+			return nil, err
+		}
+		return w, nil
+	}
+
+	if err := w.openSegment(segments[len(segments)-1]); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *WAL) AppendEntries(entries []LogEntry) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, entry := range entries {
+		rec := walRecord{
+			Type:    walRecordEntry,
+			Index:   entry.Index,
+			Term:    entry.Term,
+			Command: entry.Command,
+		}
+		if err := w.write(rec); err != nil {
+			return err
+		}
+	}
+	return w.segment.Sync()
+}
+
+func (w *WAL) DeleteRange(min, max int) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	rec := walRecord{
+		Type:     walRecordDelete,
+		Index:    min,
+		MaxIndex: max,
+	}
+	if err := w.write(rec); err != nil {
+		return err
+	}
+	return w.segment.Sync()
+}
+
+func (w *WAL) SaveState(term, votedFor int) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	rec := walRecord{
+		Type:     walRecordState,
+		Term:     term,
+		VotedFor: votedFor,
+	}
+	if err := w.write(rec); err != nil {
+		return err
+	}
+	return w.segment.Sync()
+}
+
+// Checkpoint удаляет закрытые сегменты, все записи лога в которых ушли в
+// снапшот (индекс меньше firstIndex). Term и votedFor перед этим пишутся
+// заново, чтобы не пропасть вместе со старыми сегментами.
+func (w *WAL) Checkpoint(term, votedFor, firstIndex int) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	segments, err := listSegments(w.dir)
+	if err != nil {
+		return err
+	}
+	var obsolete []int
+	for _, seq := range segments {
+		if seq == w.segSeq || w.segMaxIndex[seq] >= firstIndex {
+			break
+		}
+		obsolete = append(obsolete, seq)
+	}
+	if len(obsolete) == 0 {
+		return nil
+	}
+
+	if err := w.write(walRecord{Type: walRecordState, Term: term, VotedFor: votedFor}); err != nil {
+		return err
+	}
+	if err := w.segment.Sync(); err != nil {
+		return err
+	}
+
+	for _, seq := range obsolete {
+		if err := os.Remove(segmentPath(w.dir, seq)); err != nil {
+			return err
+		}
+		delete(w.segMaxIndex, seq)
+	}
+	return syncDir(w.dir)
+}
+
+func (w *WAL) AppendedBytes() int64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.appended
+}
+
+func (w *WAL) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.segment == nil {
+		return nil
+	}
+	err := w.segment.Sync()
+	if cerr := w.segment.Close(); err == nil {
+		err = cerr
+	}
+	w.segment = nil
+	return err
+}
+
+func (w *WAL) write(rec walRecord) error {
+	var payload bytes.Buffer
+	if err := gob.NewEncoder(&payload).Encode(rec); err != nil {
+		return err
+	}
+	// Такую запись не прочитать обратно: readRecord отвергает длину больше сегмента
+	if walHeaderSize+payload.Len() > walSegmentSize {
+		return ErrRecordTooLarge
+	}
+
+	if w.segSize > 0 && w.segSize+int64(walHeaderSize+payload.Len()) > walSegmentSize {
+		if err := w.rotate(); err != nil {
+			return err
+		}
+	}
+
+	frame := make([]byte, walHeaderSize+payload.Len())
+	binary.BigEndian.PutUint32(frame[0:4], uint32(payload.Len()))
+	binary.BigEndian.PutUint32(frame[4:8], crc32.Checksum(payload.Bytes(), walCRCTable))
+	copy(frame[walHeaderSize:], payload.Bytes())
+
+	n, err := w.segment.Write(frame)
+	w.segSize += int64(n)
+	if rec.Type == walRecordEntry {
+		w.appended += int64(n)
+		if rec.Index > w.segMaxIndex[w.segSeq] {
+			w.segMaxIndex[w.segSeq] = rec.Index
+		}
+	}
+	return err
+}
+
+func (w *WAL) rotate() error {
+	if err := w.segment.Sync(); err != nil {
+		return err
+	}
+	if err := w.segment.Close(); err != nil {
+		return err
+	}
+	return w.openSegment(w.segSeq + 1)
+}
+
+func (w *WAL) openSegment(seq int) error {
+	file, err := os.OpenFile(segmentPath(w.dir, seq), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	if err := syncDir(w.dir); err != nil {
+		file.Close()
+		return err
+	}
+
+	w.segment = file
+	w.segSeq = seq
+	w.segSize = info.Size()
+	return nil
+}
+
+func replaySegment(path string, last bool, replay func(walRecord) error) error {
+	file, err := os.OpenFile(path, os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	reader := bufio.NewReader(file)
+	var offset int64
+
+	for {
+		rec, n, err := readRecord(reader)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			// Недописанной может быть только последняя запись последнего
+			// сегмента; испорченная запись, за которой есть данные, — порча диска
+			if !last || offset+int64(n) < info.Size() {
+				return fmt.Errorf("%w in %s at offset %d", ErrCorruptWAL, path, offset)
+			}
+			if err := file.Truncate(offset); err != nil {
+				return err
+			}
+			return file.Sync()
+		}
+		offset += int64(n)
+
+		if err := replay(rec); err != nil {
+			return err
+		}
+	}
+}
+
+// readRecord возвращает размер кадра, заявленный в заголовке, и при ошибке:
+// по нему replaySegment отличает недописанный хвост от порчи в середине.
+func readRecord(r io.Reader) (walRecord, int, error) {
+	var rec walRecord
+
+	header := make([]byte, walHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		if err == io.EOF {
+			return rec, 0, io.EOF
+		}
+		return rec, walHeaderSize, ErrCorruptWAL
+	}
+
+	length := binary.BigEndian.Uint32(header[0:4])
+	checksum := binary.BigEndian.Uint32(header[4:8])
+	n := walHeaderSize + int(length)
+	if length == 0 || n > walSegmentSize {
+		return rec, n, ErrCorruptWAL
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return rec, n, ErrCorruptWAL
+	}
+	if crc32.Checksum(payload, walCRCTable) != checksum {
+		return rec, n, ErrCorruptWAL
+	}
+
+	if err := gob.NewDecoder(bytes.NewReader(payload)).Decode(&rec); err != nil {
+		return rec, n, ErrCorruptWAL
+	}
+	return rec, n, nil
+}
+
+func listSegments(dir string) ([]int, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.wal"))
+	if err != nil {
+		return nil, err
+	}
+
+	segments := make([]int, 0, len(files))
+	for _, f := range files {
+		var seq int
+		if _, err := fmt.Sscanf(filepath.Base(f), "%016d.wal", &seq); err != nil {
+			continue
+		}
+		segments = append(segments, seq)
+	}
+	sort.Ints(segments)
+	return segments, nil
+}
+
+func segmentPath(dir string, seq int) string {
+	return filepath.Join(dir, fmt.Sprintf("%016d.wal", seq))
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
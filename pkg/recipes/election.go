@@ -0,0 +1,126 @@
+package recipes
+
+import (
+	"context"
+	"errors"
+
+	"raft-kv-store/pkg/kvstore"
+)
+
+var ErrNoLeader = errors.New("recipes: election has no leader")
+
+func ElectionPrefix(name string) string {
+	return keyRoot + "election/" + name + "/"
+}
+
+// Election — выбор лидера среди сессий. Лидер — кандидат с наименьшей
+// ревизией ключа, его значение видно через Leader и Observe.
+type Election struct {
+	s      *Session
+	store  *kvstore.Store
+	prefix string
+	key    string
+	token  int
+}
+
+func NewElection(s *Session, name string) *Election {
+	return &Election{s: s, store: s.store, prefix: ElectionPrefix(name)}
+}
+
+// ObserveElection позволяет следить за выборами без участия в них.
+func ObserveElection(store *kvstore.Store, name string) *Election {
+	return &Election{store: store, prefix: ElectionPrefix(name)}
+}
+
+// Campaign блокируется, пока кандидат не станет лидером.
+func (e *Election) Campaign(ctx context.Context, value string) error {
+	key, token, err := createKey(ctx, e.s, e.prefix, value)
+	if err != nil {
+		return err
+	}
+	e.key, e.token = key, token
+
+	if err := waitTurn(ctx, e.s, e.prefix, key, token, 1); err != nil {
+		release(e.store, key)
+		e.key, e.token = "", 0
+		return err
+	}
+	return nil
+}
+
+func (e *Election) Resign(ctx context.Context) error {
+	if e.key == "" {
+		return nil
+	}
+	if _, err := e.store.Delete(ctx, e.key); err != nil {
+		return err
+	}
+	e.key, e.token = "", 0
+	return nil
+}
+
+// Leader возвращает ключ и значение текущего лидера.
+func (e *Election) Leader(ctx context.Context) (kvstore.KeyValue, error) {
+	items, _, err := waiters(ctx, e.store, e.prefix)
+	if err != nil {
+		return kvstore.KeyValue{}, err
+	}
+	if len(items) == 0 {
+		return kvstore.KeyValue{}, ErrNoLeader
+	}
+	return items[0], nil
+}
+
+// Observe отдаёт лидера при каждой смене, пока не отменён ctx.
+func (e *Election) Observe(ctx context.Context) <-chan kvstore.KeyValue {
+	ch := make(chan kvstore.KeyValue)
+	go func() {
+		defer close(ch)
+
+		var current kvstore.KeyValue
+		for {
+			items, readRev, err := waiters(ctx, e.store, e.prefix)
+			if err != nil {
+				return
+			}
+			if len(items) > 0 && items[0] != current {
+				current = items[0]
+				select {
+				case ch <- current:
+				case <-ctx.Done():
+					return
+				}
+			}
+			if err := waitChange(ctx, e.store, e.prefix, readRev+1); err != nil {
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+func (e *Election) Key() string {
+	return e.key
+}
+
+func (e *Election) Token() int {
+	return e.token
+}
+
+func waitChange(ctx context.Context, store *kvstore.Store, prefix string, from int) error {
+	w, err := store.Watch(prefix, true, from)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case _, ok := <-w.Events():
+		if !ok {
+			return w.Err()
+		}
+		return nil
+	}
+}
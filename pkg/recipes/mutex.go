@@ -0,0 +1,63 @@
+package recipes
+
+import (
+	"context"
+	"errors"
+)
+
+var ErrNotLocked = errors.New("recipes: mutex is not locked")
+
+// LockPrefix — префикс ключей очереди блокировки name.
+func LockPrefix(name string) string {
+	return keyRoot + "lock/" + name + "/"
+}
+
+// Mutex — распределённая блокировка. Претенденты встают в очередь по
+// ревизии своего ключа; владелец — ключ с наименьшей ревизией.
+type Mutex struct {
+	s      *Session
+	prefix string
+	key    string
+	token  int
+}
+
+func NewMutex(s *Session, name string) *Mutex {
+	return &Mutex{s: s, prefix: LockPrefix(name)}
+}
+
+// Lock блокируется до захвата. При отмене ctx ключ очереди удаляется.
+func (m *Mutex) Lock(ctx context.Context) error {
+	key, token, err := createKey(ctx, m.s, m.prefix, "")
+	if err != nil {
+		return err
+	}
+	m.key, m.token = key, token
+
+	if err := waitTurn(ctx, m.s, m.prefix, key, token, 1); err != nil {
+		release(m.s.store, key)
+		m.key, m.token = "", 0
+		return err
+	}
+	return nil
+}
+
+func (m *Mutex) Unlock(ctx context.Context) error {
+	if m.key == "" {
+		return ErrNotLocked
+	}
+	if _, err := m.s.store.Delete(ctx, m.key); err != nil {
+		return err
+	}
+	m.key, m.token = "", 0
+	return nil
+}
+
+func (m *Mutex) Key() string {
+	return m.key
+}
+
+// Token — fencing-токен: raft-индекс создания ключа. Растёт с каждым
+// новым захватом, хранилища данных должны отклонять запросы со старым.
+func (m *Mutex) Token() int {
+	return m.token
+}
@@ -0,0 +1,55 @@
+package recipes
+
+import "context"
+
+func SemaphorePrefix(name string) string {
+	return keyRoot + "semaphore/" + name + "/"
+}
+
+// Semaphore допускает до limit одновременных владельцев. Очередь
+// упорядочена так же, как у Mutex.
+type Semaphore struct {
+	s      *Session
+	prefix string
+	limit  int
+	key    string
+	token  int
+}
+
+func NewSemaphore(s *Session, name string, limit int) *Semaphore {
+	return &Semaphore{s: s, prefix: SemaphorePrefix(name), limit: limit}
+}
+
+func (sem *Semaphore) Acquire(ctx context.Context) error {
+	key, token, err := createKey(ctx, sem.s, sem.prefix, "")
+	if err != nil {
+		return err
+	}
+	sem.key, sem.token = key, token
+
+	if err := waitTurn(ctx, sem.s, sem.prefix, key, token, sem.limit); err != nil {
+		release(sem.s.store, key)
+		sem.key, sem.token = "", 0
+		return err
+	}
+	return nil
+}
+
+func (sem *Semaphore) Release(ctx context.Context) error {
+	if sem.key == "" {
+		return ErrNotLocked
+	}
+	if _, err := sem.s.store.Delete(ctx, sem.key); err != nil {
+		return err
+	}
+	sem.key, sem.token = "", 0
+	return nil
+}
+
+func (sem *Semaphore) Key() string {
+	return sem.key
+}
+
+func (sem *Semaphore) Token() int {
+	return sem.token
+}
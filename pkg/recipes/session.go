@@ -0,0 +1,112 @@
+package recipes
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"raft-kv-store/pkg/kvstore"
+	"raft-kv-store/pkg/raft"
+)
+
+const keyRoot = "/_recipes/"
+
+var ErrSessionExpired = errors.New("recipes: session lease expired, its keys were deleted")
+
+// Session — аренда, к которой привязаны ключи рецептов. Если сессия
+// теряется, её ключи удаляются и блокировки освобождаются.
+type Session struct {
+	store  *kvstore.Store
+	lease  int
+	cancel context.CancelFunc
+	donec  chan struct{}
+	err    error
+}
+
+// NewSession создаёт аренду с TTL и продлевает её, пока сессия не закрыта.
+// Продление принимает только лидер, поэтому сессию нужно открывать на нём.
+func NewSession(ctx context.Context, store *kvstore.Store, ttl time.Duration) (*Session, error) {
+	lease, err := store.Grant(ctx, ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	keepCtx, cancel := context.WithCancel(context.Background())
+	s := &Session{
+		store:  store,
+		lease:  lease,
+		cancel: cancel,
+		donec:  make(chan struct{}),
+	}
+	go s.keepAlive(keepCtx, ttl/3)
+	return s, nil
+}
+
+// SessionFromLease оборачивает существующую аренду. Продлевает её
+// вызывающий — так работают HTTP и gRPC эндпоинты.
+func SessionFromLease(store *kvstore.Store, lease int) *Session {
+	return &Session{store: store, lease: lease}
+}
+
+func (s *Session) Lease() int {
+	return s.lease
+}
+
+// Done закрывается, когда аренда потеряна или её больше нельзя продлить:
+// узел перестал быть лидером. Для SessionFromLease — nil.
+func (s *Session) Done() <-chan struct{} {
+	return s.donec
+}
+
+// Err возвращает причину закрытия Done.
+func (s *Session) Err() error {
+	select {
+	case <-s.donec:
+		return s.err
+	default:
+		return nil
+	}
+}
+
+// Close прекращает продление и отзывает аренду вместе с ключами.
+func (s *Session) Close(ctx context.Context) error {
+	if s.cancel != nil {
+		s.cancel()
+	}
+	err := s.store.Revoke(ctx, s.lease)
+	if err == kvstore.ErrLeaseNotFound {
+		return nil
+	}
+	return err
+}
+
+func (s *Session) keepAlive(ctx context.Context, interval time.Duration) {
+	defer close(s.donec)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			s.err = ctx.Err()
+			return
+		case <-ticker.C:
+			_, err := s.store.KeepAlive(s.lease)
+			switch err {
+			case nil:
+			case kvstore.ErrLeaseNotFound:
+				s.err = ErrSessionExpired
+				return
+			case raft.ErrNotLeader:
+				// Продлевать аренду теперь некому: она истечёт на новом лидере
+				log.Printf("recipes: lease %d can no longer be kept alive: %v", s.lease, err)
+				s.err = err
+				return
+			default:
+				log.Printf("recipes: keepalive for lease %d failed: %v", s.lease, err)
+			}
+		}
+	}
+}
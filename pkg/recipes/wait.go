@@ -0,0 +1,123 @@
+package recipes
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+
+	"raft-kv-store/pkg/kvstore"
+)
+
+var linearizable = kvstore.ReadOptions{Consistency: kvstore.ConsistencyLinearizable}
+
+// createKey атомарно создаёт ключ сессии под prefix. Если ключ уже есть
+// (та же сессия), возвращается его версия. Версия — raft-индекс записи и
+// служит токеном очереди и fencing-токеном.
+func createKey(ctx context.Context, s *Session, prefix, value string) (string, int, error) {
+	key := fmt.Sprintf("%s%x", prefix, s.lease)
+	res, err := s.store.Txn(ctx, kvstore.Txn{
+		Compares: []kvstore.Compare{{
+			Key:     key,
+			Target:  kvstore.CompareVersion,
+			Result:  kvstore.CompareEqual,
+			Version: 0,
+		}},
+		Success: []kvstore.Command{{Op: kvstore.OpSet, Key: key, Value: value, Lease: s.lease}},
+		Failure: []kvstore.Command{{Op: kvstore.OpGet, Key: key}},
+	})
+	if err != nil {
+		return "", 0, err
+	}
+	return key, res.Results[0].Version, nil
+}
+
+// waiters возвращает ключи под prefix в порядке создания и ревизию чтения.
+func waiters(ctx context.Context, store *kvstore.Store, prefix string) ([]kvstore.KeyValue, int, error) {
+	page, err := store.RangeConsistent(ctx, prefix, kvstore.PrefixEnd(prefix), kvstore.MaxScanLimit, linearizable)
+	if err != nil {
+		return nil, 0, err
+	}
+	sort.Slice(page.Items, func(i, j int) bool {
+		return page.Items[i].Version < page.Items[j].Version
+	})
+	return page.Items, page.Revision, nil
+}
+
+// waitTurn ждёт, пока ключ key с ревизией rev не окажется среди первых
+// limit ключей под prefix. Перед каждым решением ключ перечитывается: если
+// аренда истекла и ключ удалён или создан заново, возвращается
+// ErrSessionExpired.
+func waitTurn(ctx context.Context, s *Session, prefix, key string, rev, limit int) error {
+	for {
+		items, readRev, err := waiters(ctx, s.store, prefix)
+		if err != nil {
+			return err
+		}
+
+		ahead, own := 0, false
+		for _, kv := range items {
+			if kv.Version < rev {
+				ahead++
+			}
+			if kv.Key == key {
+				own = kv.Version == rev
+			}
+		}
+		if !own {
+			return ErrSessionExpired
+		}
+		if ahead < limit {
+			return nil
+		}
+
+		// Для мьютекса ждём только предшественника, иначе — любого
+		// освобождения под prefix. Удаление своего ключа будит в обоих случаях
+		if limit == 1 {
+			err = waitDelete(ctx, s, prefix, readRev+1, items[ahead-1].Key, key)
+		} else {
+			err = waitDelete(ctx, s, prefix, readRev+1)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// waitDelete ждёт удаления под prefix, начиная с ревизии from, одного из
+// keys, а если keys пуст — любого ключа. Потеря сессии прерывает ожидание.
+func waitDelete(ctx context.Context, s *Session, prefix string, from int, keys ...string) error {
+	w, err := s.store.Watch(prefix, true, from)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-s.Done():
+			return s.Err()
+		case ev, ok := <-w.Events():
+			if !ok {
+				return w.Err()
+			}
+			if ev.Type == kvstore.EventDelete && (len(keys) == 0 || slices.Contains(keys, ev.Key)) {
+				return nil
+			}
+		}
+	}
+}
+
+// release удаляет ключ, не зависая на отменённом контексте вызывающего.
+func release(store *kvstore.Store, key string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), kvstore.DefaultProposeTimeout)
+	defer cancel()
+
+	_, err := store.Delete(ctx, key)
+	if err == kvstore.ErrKeyNotFound {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,84 @@
+package tests
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"testing"
+	"time"
+
+	"raft-kv-store/pkg/raft"
+)
+
+// counterFSM — минимальный автомат поверх того же ядра: складывает
+// предложенные числа.
+type counterFSM struct {
+	total   int
+	indexes []int
+}
+
+func (c *counterFSM) Apply(entry raft.LogEntry) interface{} {
+	n, ok := entry.Command.(int)
+	if !ok {
+		return nil
+	}
+	c.total += n
+	c.indexes = append(c.indexes, entry.Index)
+	return c.total
+}
+
+func (c *counterFSM) Snapshot() ([]byte, error) {
+	return binary.AppendVarint(nil, int64(c.total)), nil
+}
+
+func (c *counterFSM) Restore(r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	total, _ := binary.Varint(data)
+	c.total = int(total)
+	return nil
+}
+
+func waitLeader(t *testing.T, node *raft.RaftNode) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for !node.IsLeader() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Node did not become leader")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestCustomFSM(t *testing.T) {
+	node := raft.NewRaftNode(1, []string{})
+	fsm := &counterFSM{}
+	node.SetFSM(fsm)
+	go node.Run()
+	defer node.Stop()
+	waitLeader(t, node)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	prev := 0
+	for i, n := range []int{1, 2, 3} {
+		future := node.Propose(n)
+		result, err := future.Wait(ctx)
+		if err != nil {
+			t.Fatalf("Propose %d failed: %v", i, err)
+		}
+		if future.Index() <= prev {
+			t.Fatalf("Expected increasing log index, got %d after %d", future.Index(), prev)
+		}
+		prev = future.Index()
+		if want := (i + 1) * (i + 2) / 2; result != want {
+			t.Fatalf("Expected running total %d, got %v", want, result)
+		}
+	}
+	if fsm.indexes[len(fsm.indexes)-1] != prev {
+		t.Fatalf("Expected FSM to see index %d, got %v", prev, fsm.indexes)
+	}
+}
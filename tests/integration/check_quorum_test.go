@@ -0,0 +1,40 @@
+package integration
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCheckQuorumIsolatedLeaderStepsDown(t *testing.T) {
+	cluster := newTestCluster(t, 3)
+	old, store := cluster.leader(t)
+
+	oldID := 0
+	for i, node := range cluster.nodes {
+		if node == old {
+			oldID = i + 1
+		}
+	}
+
+	cluster.isolate(oldID)
+
+	// Лидер уходит через minElectionTimeout плюс период heartbeat
+	deadline := time.Now().Add(5 * time.Second)
+	for old.IsLeader() && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+	}
+	if old.IsLeader() {
+		t.Fatalf("Leader without quorum contact did not step down")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := store.Propose(ctx, "key", "value"); err == nil {
+		t.Fatalf("Isolated node accepted a proposal")
+	}
+
+	if next, _ := cluster.leader(t); next == old {
+		t.Fatalf("Isolated node is still leader")
+	}
+}
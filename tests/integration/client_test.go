@@ -0,0 +1,270 @@
+package integration
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"raft-kv-store/pkg/api"
+	"raft-kv-store/pkg/client"
+	"raft-kv-store/pkg/kvstore"
+	pb "raft-kv-store/proto"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestClientFollowsLeader(t *testing.T) {
+	cluster := newTestCluster(t, 3)
+	leader, _ := cluster.leader(t)
+
+	// Первым в списке идёт follower: клиент должен найти лидера сам
+	var endpoints []string
+	for i, node := range cluster.nodes {
+		if node != leader {
+			endpoints = append(endpoints, cluster.addrs[i])
+		}
+	}
+	for i, node := range cluster.nodes {
+		if node == leader {
+			endpoints = append(endpoints, cluster.addrs[i])
+		}
+	}
+
+	c, err := client.New(client.Config{Endpoints: endpoints})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	watcher := c.Watch(ctx, "cfg/", true, 1)
+	defer watcher.Close()
+
+	version, err := c.Put(ctx, "cfg/a", "1")
+	if err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	kv, err := c.Get(ctx, "cfg/a")
+	if err != nil || kv.Value != "1" || kv.Version != version {
+		t.Fatalf("Expected cfg/a=1@%d, got %+v (%v)", version, kv, err)
+	}
+
+	if err := c.Delete(ctx, "cfg/missing"); err != kvstore.ErrKeyNotFound {
+		t.Errorf("Expected ErrKeyNotFound, got %v", err)
+	}
+
+	select {
+	case ev := <-watcher.Events():
+		if ev.Type != kvstore.EventPut || ev.Key != "cfg/a" || ev.Revision != version {
+			t.Errorf("Unexpected watch event %+v", ev)
+		}
+	case <-ctx.Done():
+		t.Fatalf("No watch event received")
+	}
+}
+
+// Стрим подписки с fromRevision = 0 обрывается до первого события: клиент
+// переподключается с ревизии из заголовка и получает запись, сделанную,
+// пока он был отключён.
+func TestClientWatchFromZeroSurvivesReconnect(t *testing.T) {
+	cluster := newTestCluster(t, 3)
+	_, leaderStore := cluster.leader(t)
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	addr := lis.Addr().String()
+	headerSent := make(chan struct{}, 1)
+	serve := func(lis net.Listener) *grpc.Server {
+		server := grpc.NewServer(grpc.StreamInterceptor(
+			func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+				return handler(srv, &headerStream{ServerStream: ss, sent: headerSent})
+			}))
+		pb.RegisterKeyValueServiceServer(server, api.NewKeyValueService(cluster.stores[0], cluster.nodes[0]))
+		go server.Serve(lis)
+		return server
+	}
+	server := serve(lis)
+
+	c, err := client.New(client.Config{Endpoints: []string{addr}})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	watcher := c.Watch(ctx, "w/", true, 0)
+	defer watcher.Close()
+
+	select {
+	case <-headerSent:
+	case <-ctx.Done():
+		t.Fatalf("Watch was not established")
+	}
+	// Даём заголовку дойти до клиента до обрыва соединения
+	time.Sleep(200 * time.Millisecond)
+	server.Stop()
+
+	if err := leaderStore.Put("w/a", "1"); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	waitFor(t, "write to reach the watched node", func() bool {
+		_, err := cluster.stores[0].Get("w/a")
+		return err == nil
+	})
+
+	lis, err = net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatalf("Failed to listen again: %v", err)
+	}
+	defer serve(lis).Stop()
+
+	select {
+	case ev := <-watcher.Events():
+		if ev.Type != kvstore.EventPut || ev.Key != "w/a" {
+			t.Errorf("Unexpected watch event %+v", ev)
+		}
+	case <-ctx.Done():
+		t.Fatalf("Event written during reconnect was lost")
+	}
+}
+
+// Параллельные записи одного клиента не должны отклоняться как повторы.
+func TestClientConcurrentWrites(t *testing.T) {
+	cluster := newTestCluster(t, 3)
+	cluster.leader(t)
+
+	c, err := client.New(client.Config{Endpoints: cluster.addrs})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if _, err := c.Put(ctx, fmt.Sprintf("c/%d", i), "v"); err != nil {
+				errs <- err
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("Concurrent Put failed: %v", err)
+	}
+}
+
+// Стрим обрывается после первого события транзакции из трёх ключей:
+// клиент возобновляет watch с той же ревизии и получает остальные два
+// без повторов.
+func TestClientWatchResumesWithinRevision(t *testing.T) {
+	cluster := newTestCluster(t, 3)
+	_, leaderStore := cluster.leader(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	res, err := leaderStore.Txn(ctx, kvstore.Txn{Success: []kvstore.Command{
+		{Op: kvstore.OpSet, Key: "t/a", Value: "1"},
+		{Op: kvstore.OpSet, Key: "t/b", Value: "2"},
+		{Op: kvstore.OpSet, Key: "t/c", Value: "3"},
+	}})
+	if err != nil || !res.Succeeded {
+		t.Fatalf("Txn failed: %+v, %v", res, err)
+	}
+	revision := res.Results[0].Version
+	waitFor(t, "txn to reach the watched node", func() bool {
+		_, err := cluster.stores[0].Get("t/c")
+		return err == nil
+	})
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	var sends atomic.Int32
+	server := grpc.NewServer(grpc.StreamInterceptor(
+		func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			return handler(srv, &cutStream{ServerStream: ss, sends: &sends})
+		}))
+	pb.RegisterKeyValueServiceServer(server, api.NewKeyValueService(cluster.stores[0], cluster.nodes[0]))
+	go server.Serve(lis)
+	defer server.Stop()
+
+	c, err := client.New(client.Config{Endpoints: []string{lis.Addr().String()}})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer c.Close()
+
+	watcher := c.Watch(ctx, "t/", true, revision)
+	defer watcher.Close()
+
+	for _, key := range []string{"t/a", "t/b", "t/c"} {
+		select {
+		case ev := <-watcher.Events():
+			if ev.Key != key || ev.Revision != revision {
+				t.Fatalf("Expected %s@%d, got %+v", key, revision, ev)
+			}
+		case <-ctx.Done():
+			t.Fatalf("Event %s was lost on reconnect", key)
+		}
+	}
+	if sends.Load() < 2 {
+		t.Fatalf("Stream was not cut")
+	}
+
+	select {
+	case ev := <-watcher.Events():
+		t.Fatalf("Unexpected extra event %+v", ev)
+	case <-time.After(300 * time.Millisecond):
+	}
+}
+
+// cutStream обрывает второй отправленный ответ среди всех стримов.
+type cutStream struct {
+	grpc.ServerStream
+	sends *atomic.Int32
+}
+
+func (s *cutStream) SendMsg(m interface{}) error {
+	if s.sends.Add(1) == 2 {
+		return status.Error(codes.Unavailable, "cut")
+	}
+	return s.ServerStream.SendMsg(m)
+}
+
+// headerStream сообщает в sent, что заголовки ответа отправлены.
+type headerStream struct {
+	grpc.ServerStream
+	sent chan struct{}
+}
+
+func (s *headerStream) SendHeader(md metadata.MD) error {
+	err := s.ServerStream.SendHeader(md)
+	select {
+	case s.sent <- struct{}{}:
+	default:
+	}
+	return err
+}
@@ -1,11 +1,16 @@
 package integration
 
 import (
+	"net"
 	"testing"
 	"time"
 
+	"raft-kv-store/pkg/api"
 	"raft-kv-store/pkg/kvstore"
 	"raft-kv-store/pkg/raft"
+	pb "raft-kv-store/proto"
+
+	"google.golang.org/grpc"
 )
 
 func TestClusterFormation(t *testing.T) {
@@ -13,16 +18,46 @@ func TestClusterFormation(t *testing.T) {
 	nodes := make([]*raft.RaftNode, 3)
 	stores := make([]*kvstore.Store, 3)
 
+	listeners := make([]net.Listener, 3)
+	addrs := make([]string, 3)
+	for i := range listeners {
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Failed to listen: %v", err)
+		}
+		listeners[i] = lis
+		addrs[i] = lis.Addr().String()
+	}
+
 	for i := 0; i < 3; i++ {
-		node := raft.NewRaftNode(i+1, []string{"node1", "node2", "node3"})
+		var peers []string
+		for j, addr := range addrs {
+			if j != i {
+				peers = append(peers, addr)
+			}
+		}
+		node := raft.NewRaftNode(i+1, peers)
 		store := kvstore.NewStore(node)
 		nodes[i] = node
 		stores[i] = store
+
+		server := grpc.NewServer()
+		pb.RegisterRaftServiceServer(server, api.NewRaftService(node))
+		go server.Serve(listeners[i])
+		defer server.Stop()
 		go node.Run()
 	}
 
 	// Ожидание выбора лидера
-	time.Sleep(5 * time.Second)
+	leader := -1
+	for deadline := time.Now().Add(15 * time.Second); leader < 0 && time.Now().Before(deadline); {
+		time.Sleep(100 * time.Millisecond)
+		for i, node := range nodes {
+			if node.IsLeader() {
+				leader = i
+			}
+		}
+	}
 
 	// Проверка наличия лидера
 	leaderCount := 0
@@ -36,15 +71,19 @@ func TestClusterFormation(t *testing.T) {
 	}
 
 	// Проверка репликации данных
-	leaderStore := stores[0]
+	leaderStore := stores[leader]
 	if err := leaderStore.Put("key1", "value1"); err != nil {
 		t.Fatalf("Failed to put value: %v", err)
 	}
 
-	time.Sleep(1 * time.Second)
-
+	// Запись применяется асинхронно: фоловеры узнают о коммите
+	// со следующим heartbeat
 	for _, store := range stores {
 		value, err := store.Get("key1")
+		for deadline := time.Now().Add(5 * time.Second); err != nil && time.Now().Before(deadline); {
+			time.Sleep(100 * time.Millisecond)
+			value, err = store.Get("key1")
+		}
 		if err != nil {
 			t.Fatalf("Failed to get value: %v", err)
 		}
@@ -0,0 +1,214 @@
+package integration
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"raft-kv-store/pkg/api"
+	"raft-kv-store/pkg/kvstore"
+	"raft-kv-store/pkg/raft"
+	pb "raft-kv-store/proto"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// testCluster — кластер в одном процессе: каждый узел слушает свой
+// gRPC-порт на localhost, узлы общаются через настоящий транспорт.
+// Изолированным узлам серверы отказывают в raft-RPC в обе стороны.
+type testCluster struct {
+	addrs   []string
+	nodes   []*raft.RaftNode
+	stores  []*kvstore.Store
+	servers []*grpc.Server
+
+	// Если snapshots задан, узлы хранят снапшоты в dataDir/<id>
+	snapshots *raft.SnapshotConfig
+	dataDir   string
+
+	mu       sync.Mutex
+	isolated map[int]bool
+}
+
+func newTestCluster(t *testing.T, size int) *testCluster {
+	t.Helper()
+	return newCluster(t, size, nil)
+}
+
+// newSnapshotCluster запускает кластер, узлы которого делают снапшоты по
+// config.
+func newSnapshotCluster(t *testing.T, size int, config raft.SnapshotConfig) *testCluster {
+	t.Helper()
+	return newCluster(t, size, &config)
+}
+
+func newCluster(t *testing.T, size int, snapshots *raft.SnapshotConfig) *testCluster {
+	t.Helper()
+
+	listeners := make([]net.Listener, size)
+	c := &testCluster{
+		addrs:     make([]string, size),
+		snapshots: snapshots,
+		dataDir:   t.TempDir(),
+		isolated:  make(map[int]bool),
+	}
+	for i := range listeners {
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Failed to listen: %v", err)
+		}
+		listeners[i] = lis
+		c.addrs[i] = lis.Addr().String()
+	}
+
+	for i := 0; i < size; i++ {
+		var peers []string
+		for j, addr := range c.addrs {
+			if j != i {
+				peers = append(peers, addr)
+			}
+		}
+		if err := c.start(i+1, listeners[i], peers, false); err != nil {
+			t.Fatalf("Failed to start node %d: %v", i+1, err)
+		}
+	}
+
+	t.Cleanup(c.stop)
+	return c
+}
+
+// join запускает узел вне конфигурации; в кластер его добавляет AddMember.
+// Возвращает id и адрес нового узла.
+func (c *testCluster) join(t *testing.T) (int, string) {
+	t.Helper()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	c.addrs = append(c.addrs, lis.Addr().String())
+	id := len(c.nodes) + 1
+	if err := c.start(id, lis, nil, true); err != nil {
+		t.Fatalf("Failed to start node %d: %v", id, err)
+	}
+	return id, lis.Addr().String()
+}
+
+func (c *testCluster) start(id int, lis net.Listener, peers []string, joining bool) error {
+	node := raft.NewRaftNode(id, peers)
+	node.SetRPCAddr(lis.Addr().String())
+	if joining {
+		node.SetJoining()
+	}
+	if c.snapshots != nil {
+		if _, err := raft.NewSnapshotStore(node, c.nodeDir(id), false, *c.snapshots); err != nil {
+			return err
+		}
+	}
+	store := kvstore.NewStore(node)
+
+	server := grpc.NewServer(
+		grpc.UnaryInterceptor(c.unaryPartition(id)),
+		grpc.StreamInterceptor(c.streamPartition(id)),
+	)
+	pb.RegisterRaftServiceServer(server, api.NewRaftService(node))
+	pb.RegisterKeyValueServiceServer(server, api.NewKeyValueService(store, node))
+	go server.Serve(lis)
+	go node.Run()
+
+	c.nodes = append(c.nodes, node)
+	c.stores = append(c.stores, store)
+	c.servers = append(c.servers, server)
+	return nil
+}
+
+func (c *testCluster) nodeDir(id int) string {
+	return filepath.Join(c.dataDir, strconv.Itoa(id))
+}
+
+// isolate отрезает узел id от остальных, heal снимает все изоляции.
+func (c *testCluster) isolate(id int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.isolated[id] = true
+}
+
+func (c *testCluster) heal() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.isolated = make(map[int]bool)
+}
+
+func (c *testCluster) blocked(from, to int) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.isolated[from] || c.isolated[to]
+}
+
+// unaryPartition узнаёт отправителя по id кандидата или лидера в запросе.
+func (c *testCluster) unaryPartition(to int) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		from := 0
+		switch r := req.(type) {
+		case *pb.RequestVoteRequest:
+			from = int(r.CandidateId)
+		case *pb.AppendEntriesRequest:
+			from = int(r.LeaderId)
+		case *pb.TimeoutNowRequest:
+			from = int(r.LeaderId)
+		default:
+			return handler(ctx, req)
+		}
+		if c.blocked(from, to) {
+			return nil, status.Error(codes.Unavailable, "partitioned")
+		}
+		return handler(ctx, req)
+	}
+}
+
+// streamPartition режет InstallSnapshot на изолированный узел; снапшоты
+// шлёт только лидер, поэтому изоляции получателя достаточно.
+func (c *testCluster) streamPartition(to int) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if info.FullMethod == "/raft.RaftService/InstallSnapshot" && c.blocked(0, to) {
+			return status.Error(codes.Unavailable, "partitioned")
+		}
+		return handler(srv, ss)
+	}
+}
+
+// leader ждёт, пока среди неизолированных узлов не окажется ровно один
+// лидер.
+func (c *testCluster) leader(t *testing.T) (*raft.RaftNode, *kvstore.Store) {
+	t.Helper()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		var leaders []int
+		for i, node := range c.nodes {
+			if node.IsLeader() && !c.blocked(0, i+1) {
+				leaders = append(leaders, i)
+			}
+		}
+		if len(leaders) == 1 {
+			return c.nodes[leaders[0]], c.stores[leaders[0]]
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	t.Fatalf("No single leader elected")
+	return nil, nil
+}
+
+func (c *testCluster) stop() {
+	for i, node := range c.nodes {
+		c.servers[i].Stop()
+		c.stores[i].Close()
+		node.Stop()
+	}
+}
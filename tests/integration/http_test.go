@@ -0,0 +1,196 @@
+package integration
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"raft-kv-store/pkg/api"
+	"raft-kv-store/pkg/kvstore"
+
+	"github.com/gorilla/mux"
+)
+
+// Фоловер не перенаправляет HTTP-запрос на gRPC-адрес лидера, а отвечает
+// 503 с подсказкой.
+func TestHTTPFollowerReportsLeader(t *testing.T) {
+	cluster := newTestCluster(t, 3)
+	leader, _ := cluster.leader(t)
+
+	follower := 0
+	for i, node := range cluster.nodes {
+		if node != leader {
+			follower = i
+			break
+		}
+	}
+	node := cluster.nodes[follower]
+	waitFor(t, "follower to learn the leader", func() bool {
+		return node.GetLeader() == leader.GetLeader()
+	})
+
+	router := mux.NewRouter()
+	server := api.NewHTTPServer(cluster.stores[follower], node)
+	router.HandleFunc("/key/{key}", server.HandlePutKey).Methods("PUT")
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("PUT", "/key/k?value=v", nil))
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected 503, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("X-Raft-Leader"); got != leader.GetLeader() {
+		t.Fatalf("Expected leader hint %q, got %q", leader.GetLeader(), got)
+	}
+	if rec.Header().Get("Location") != "" {
+		t.Fatalf("Unexpected redirect to %q", rec.Header().Get("Location"))
+	}
+}
+
+// leaderRouter обслуживает запросы к ключам на текущем лидере.
+func leaderRouter(t *testing.T, cluster *testCluster) *mux.Router {
+	t.Helper()
+	leader, store := cluster.leader(t)
+	server := api.NewHTTPServer(store, leader)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/key/{key}", server.HandleGetKey).Methods("GET")
+	router.HandleFunc("/key/{key}", server.HandlePutKey).Methods("PUT")
+	router.HandleFunc("/key/{key}", server.HandleDeleteKey).Methods("DELETE")
+	router.HandleFunc("/txn", server.HandleTxn).Methods("POST")
+	router.HandleFunc("/watch", server.HandleWatch).Methods("GET")
+	return router
+}
+
+func serve(router *mux.Router, method, target string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(method, target, nil))
+	return rec
+}
+
+// Отсутствие ключа решает результат применения, а не предварительное чтение.
+func TestHTTPDeleteKey(t *testing.T) {
+	cluster := newTestCluster(t, 3)
+	router := leaderRouter(t, cluster)
+
+	if rec := serve(router, "PUT", "/key/k?value=v"); rec.Code != http.StatusOK {
+		t.Fatalf("PUT: expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	if rec := serve(router, "DELETE", "/key/k"); rec.Code != http.StatusOK {
+		t.Fatalf("DELETE: expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	if rec := serve(router, "GET", "/key/k?consistency=linearizable"); rec.Code != http.StatusNotFound {
+		t.Fatalf("GET after DELETE: expected 404, got %d", rec.Code)
+	}
+	if rec := serve(router, "DELETE", "/key/k"); rec.Code != http.StatusNotFound {
+		t.Fatalf("Second DELETE: expected 404, got %d", rec.Code)
+	}
+}
+
+func TestHTTPConditionalPut(t *testing.T) {
+	cluster := newTestCluster(t, 3)
+	router := leaderRouter(t, cluster)
+	_, store := cluster.leader(t)
+
+	if rec := serve(router, "PUT", "/key/k?value=a&if_absent=true"); rec.Code != http.StatusOK {
+		t.Fatalf("if_absent on a new key: expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	created, _ := store.GetKV("k")
+
+	rec := serve(router, "PUT", "/key/k?value=b&if_absent=true")
+	if rec.Code != http.StatusPreconditionFailed {
+		t.Fatalf("if_absent on an existing key: expected 412, got %d", rec.Code)
+	}
+	var conflict struct{ Version int }
+	if err := json.Unmarshal(rec.Body.Bytes(), &conflict); err != nil || conflict.Version != created.Version {
+		t.Fatalf("Expected current version %d in the conflict, got %s", created.Version, rec.Body)
+	}
+
+	if rec := serve(router, "PUT", "/key/k?value=c&prev_value=b"); rec.Code != http.StatusPreconditionFailed {
+		t.Fatalf("CAS with a wrong prev_value: expected 412, got %d", rec.Code)
+	}
+	if rec := serve(router, "PUT", "/key/k?value=c&prev_value=a"); rec.Code != http.StatusOK {
+		t.Fatalf("CAS: expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	if kv, _ := store.GetKV("k"); kv.Value != "c" {
+		t.Fatalf("Expected c after CAS, got %q", kv.Value)
+	}
+
+	// Условная запись не теряет аренду
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	leaseID, err := store.Grant(ctx, 10*time.Second)
+	if err != nil {
+		t.Fatalf("Grant failed: %v", err)
+	}
+	target := fmt.Sprintf("/key/leased?value=v&if_absent=true&lease=%d", leaseID)
+	if rec := serve(router, "PUT", target); rec.Code != http.StatusOK {
+		t.Fatalf("if_absent with lease: expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	target = fmt.Sprintf("/key/leased?value=w&prev_value=v&lease=%d", leaseID)
+	if rec := serve(router, "PUT", target); rec.Code != http.StatusOK {
+		t.Fatalf("CAS with lease: expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	if kv, _ := store.GetKV("leased"); kv.Lease != leaseID {
+		t.Fatalf("Expected key attached to lease %d, got %d", leaseID, kv.Lease)
+	}
+}
+
+// Переподключение с Last-Event-ID посреди ревизии, изменившей несколько
+// ключей, отдаёт только оставшиеся события этой ревизии.
+func TestHTTPWatchResumesWithinRevision(t *testing.T) {
+	cluster := newTestCluster(t, 3)
+	server := httptest.NewServer(leaderRouter(t, cluster))
+	defer server.Close()
+	_, store := cluster.leader(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	txn := kvstore.Txn{Success: []kvstore.Command{
+		{Op: kvstore.OpSet, Key: "app/a", Value: "1"},
+		{Op: kvstore.OpSet, Key: "app/b", Value: "2"},
+		{Op: kvstore.OpSet, Key: "app/c", Value: "3"},
+	}}
+	if _, err := store.Txn(ctx, txn); err != nil {
+		t.Fatalf("Txn failed: %v", err)
+	}
+	kv, _ := store.GetKV("app/a")
+
+	req, _ := http.NewRequestWithContext(ctx, "GET", server.URL+"/watch?prefix=app/", nil)
+	req.Header.Set("Last-Event-ID", fmt.Sprintf("%d.1", kv.Version))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Watch request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var ids []string
+	var events []kvstore.Event
+	scanner := bufio.NewScanner(resp.Body)
+	for len(events) < 2 && scanner.Scan() {
+		line := scanner.Text()
+		if id, ok := strings.CutPrefix(line, "id: "); ok {
+			ids = append(ids, id)
+		}
+		if data, ok := strings.CutPrefix(line, "data: "); ok {
+			var ev kvstore.Event
+			if err := json.Unmarshal([]byte(data), &ev); err != nil {
+				t.Fatalf("Invalid event %q: %v", data, err)
+			}
+			events = append(events, ev)
+		}
+	}
+	if len(events) != 2 || events[0].Key != "app/b" || events[1].Key != "app/c" {
+		t.Fatalf("Expected app/b and app/c, got %+v", events)
+	}
+	want := []string{fmt.Sprintf("%d.2", kv.Version), fmt.Sprintf("%d.3", kv.Version)}
+	if len(ids) != 2 || ids[0] != want[0] || ids[1] != want[1] {
+		t.Fatalf("Expected ids %v, got %v", want, ids)
+	}
+}
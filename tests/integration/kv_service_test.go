@@ -0,0 +1,96 @@
+package integration
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	pb "raft-kv-store/proto"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+func dialKV(t *testing.T, addr string) pb.KeyValueServiceClient {
+	t.Helper()
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("Failed to dial %s: %v", addr, err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return pb.NewKeyValueServiceClient(conn)
+}
+
+func TestKeyValueService(t *testing.T) {
+	cluster := newTestCluster(t, 3)
+	leader, _ := cluster.leader(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var kv, follower pb.KeyValueServiceClient
+	for i, node := range cluster.nodes {
+		if node == leader {
+			kv = dialKV(t, cluster.addrs[i])
+		} else if follower == nil {
+			follower = dialKV(t, cluster.addrs[i])
+		}
+	}
+
+	put, err := kv.Put(ctx, &pb.PutRequest{Key: "k", Value: "v"})
+	if err != nil || !put.Success || put.Version == 0 {
+		t.Fatalf("Put failed: %+v, %v", put, err)
+	}
+	get, err := kv.Get(ctx, &pb.GetRequest{Key: "k", Consistency: "linearizable"})
+	if err != nil || !get.Success || get.Value != "v" || get.Version != put.Version {
+		t.Fatalf("Expected v at version %d, got %+v, %v", put.Version, get, err)
+	}
+	if get, err := kv.Get(ctx, &pb.GetRequest{Key: "missing"}); err != nil || get.Success {
+		t.Fatalf("Expected missing key to be reported, got %+v, %v", get, err)
+	}
+
+	_, err = follower.Put(ctx, &pb.PutRequest{Key: "k", Value: "w"})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("Expected FailedPrecondition from follower, got %v", err)
+	}
+
+	resp, err := kv.ClusterStatus(ctx, &pb.StatusRequest{})
+	if err != nil {
+		t.Fatalf("ClusterStatus failed: %v", err)
+	}
+	leaders := 0
+	for _, node := range resp.Nodes {
+		if node.IsLeader {
+			leaders++
+		}
+	}
+	if len(resp.Nodes) != 3 || leaders != 1 {
+		t.Fatalf("Expected 3 nodes and one leader, got %+v", resp.Nodes)
+	}
+}
+
+func TestKeyValueServiceDelete(t *testing.T) {
+	cluster := newTestCluster(t, 3)
+	leader, _ := cluster.leader(t)
+
+	var kv pb.KeyValueServiceClient
+	for i, node := range cluster.nodes {
+		if node == leader {
+			kv = dialKV(t, cluster.addrs[i])
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := kv.Put(ctx, &pb.PutRequest{Key: "k", Value: "v"}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if resp, err := kv.Delete(ctx, &pb.DeleteRequest{Key: "k"}); err != nil || !resp.Success {
+		t.Fatalf("Expected delete to succeed, got %+v, %v", resp, err)
+	}
+	if resp, err := kv.Delete(ctx, &pb.DeleteRequest{Key: "k"}); err != nil || resp.Success {
+		t.Fatalf("Expected delete of an absent key to report failure, got %+v, %v", resp, err)
+	}
+}
@@ -0,0 +1,129 @@
+package integration
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestAddMember(t *testing.T) {
+	cluster := newTestCluster(t, 3)
+	leader, store := cluster.leader(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if _, err := store.Propose(ctx, "before", "1"); err != nil {
+		t.Fatalf("Failed to put: %v", err)
+	}
+
+	id, addr := cluster.join(t)
+	if _, err := leader.AddMember(id, addr).Wait(ctx); err != nil {
+		t.Fatalf("Failed to add member: %v", err)
+	}
+	if n := len(leader.Configuration().Members); n != 4 {
+		t.Fatalf("Expected 4 members, got %d", n)
+	}
+
+	if _, err := store.Propose(ctx, "after", "2"); err != nil {
+		t.Fatalf("Failed to put: %v", err)
+	}
+
+	joined := cluster.stores[id-1]
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		if v, err := joined.Get("after"); err == nil && v == "2" {
+			if v, _ := joined.Get("before"); v != "1" {
+				t.Fatalf("Expected earlier entries on the new member, got %q", v)
+			}
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	t.Fatalf("New member did not catch up")
+}
+
+func TestRemoveLeader(t *testing.T) {
+	cluster := newTestCluster(t, 3)
+	leader, _ := cluster.leader(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	removed := leader.Configuration()
+	var leaderID int
+	for _, m := range removed.Members {
+		if m.Address == leader.GetLeader() {
+			leaderID = m.ID
+		}
+	}
+	if _, err := leader.RemoveMember(leaderID).Wait(ctx); err != nil {
+		t.Fatalf("Failed to remove leader: %v", err)
+	}
+	if leader.IsLeader() {
+		t.Fatalf("Removed leader kept leadership")
+	}
+
+	next, store := cluster.leader(t)
+	if next == leader {
+		t.Fatalf("Removed node was re-elected")
+	}
+	if n := len(next.Configuration().Members); n != 2 {
+		t.Fatalf("Expected 2 members, got %d", n)
+	}
+	if _, err := store.Propose(ctx, "key", "value"); err != nil {
+		t.Fatalf("Failed to put after removal: %v", err)
+	}
+}
+
+func TestLearnerPromotion(t *testing.T) {
+	cluster := newTestCluster(t, 3)
+	leader, store := cluster.leader(t)
+	leader.SetLearnerPromoteLag(0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	for i := 0; i < 50; i++ {
+		if _, err := store.Propose(ctx, "key", "value"); err != nil {
+			t.Fatalf("Failed to put: %v", err)
+		}
+	}
+
+	id, addr := cluster.join(t)
+	if _, err := leader.AddMember(id, addr).Wait(ctx); err != nil {
+		t.Fatalf("Failed to add member: %v", err)
+	}
+
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		for _, node := range leader.GetClusterStatus().Nodes {
+			if node.ID != id || node.Role == "learner" {
+				continue
+			}
+			if node.Role != "follower" {
+				t.Fatalf("Expected promoted member to be a follower, got %q", node.Role)
+			}
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	t.Fatalf("Learner was not promoted")
+}
+
+// Единственный голосующий фиксирует смену состава сам, не дожидаясь
+// ответа нового участника.
+func TestAddMemberToSingleNode(t *testing.T) {
+	cluster := newTestCluster(t, 1)
+	leader, _ := cluster.leader(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := leader.AddMember(2, "127.0.0.1:1").Wait(ctx); err != nil {
+		t.Fatalf("Failed to add unreachable member: %v", err)
+	}
+	if n := len(leader.Configuration().Members); n != 2 {
+		t.Fatalf("Expected 2 members, got %d", n)
+	}
+}
@@ -0,0 +1,83 @@
+package integration
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// Узел, отрезанный дольше нескольких таймаутов выборов, не наращивает терм
+// и после возвращения не сбивает действующего лидера.
+func TestPreVotePartitionedFollower(t *testing.T) {
+	cluster := newTestCluster(t, 3)
+	leader, _ := cluster.leader(t)
+	term := leader.GetClusterStatus().Term
+
+	follower := 0
+	for i, node := range cluster.nodes {
+		if node != leader {
+			follower = i + 1
+			break
+		}
+	}
+
+	cluster.isolate(follower)
+	time.Sleep(8 * time.Second)
+
+	if got := cluster.nodes[follower-1].GetClusterStatus().Term; got != term {
+		t.Fatalf("Partitioned follower moved from term %d to %d", term, got)
+	}
+
+	cluster.heal()
+	time.Sleep(3 * time.Second)
+
+	if !leader.IsLeader() {
+		t.Fatalf("Leader was disrupted by the rejoining follower")
+	}
+	if got := leader.GetClusterStatus().Term; got != term {
+		t.Fatalf("Leader term changed from %d to %d", term, got)
+	}
+}
+
+func TestPreVoteIsolatedLeader(t *testing.T) {
+	cluster := newTestCluster(t, 3)
+	old, _ := cluster.leader(t)
+
+	oldID := 0
+	for i, node := range cluster.nodes {
+		if node == old {
+			oldID = i + 1
+		}
+	}
+
+	cluster.isolate(oldID)
+	next, store := cluster.leader(t)
+	if next == old {
+		t.Fatalf("Isolated leader is still the only leader")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := store.Propose(ctx, "key", "value"); err != nil {
+		t.Fatalf("Majority failed to commit: %v", err)
+	}
+
+	cluster.heal()
+	deadline := time.Now().Add(10 * time.Second)
+	for old.IsLeader() && time.Now().Before(deadline) {
+		time.Sleep(100 * time.Millisecond)
+	}
+	if old.IsLeader() {
+		t.Fatalf("Old leader did not step down after the partition healed")
+	}
+	cluster.leader(t)
+
+	rejoined := cluster.stores[oldID-1]
+	for time.Now().Before(deadline) {
+		if v, err := rejoined.Get("key"); err == nil && v == "value" {
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	t.Fatalf("Old leader did not catch up after the partition healed")
+}
@@ -0,0 +1,69 @@
+package integration
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"raft-kv-store/pkg/kvstore"
+	"raft-kv-store/pkg/raft"
+)
+
+func TestProposeResolvesWithApplyResult(t *testing.T) {
+	cluster := newTestCluster(t, 3)
+	leader, _ := cluster.leader(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	future := leader.Propose(kvstore.Command{Op: kvstore.OpSet, Key: "k", Value: "v"})
+	result, err := future.Wait(ctx)
+	if err != nil {
+		t.Fatalf("Propose failed: %v", err)
+	}
+	res, ok := result.(kvstore.ApplyResult)
+	if !ok || !res.Succeeded || res.Version != future.Index() {
+		t.Fatalf("Expected success at index %d, got %+v", future.Index(), result)
+	}
+
+	for _, node := range cluster.nodes {
+		if node == leader {
+			continue
+		}
+		if _, err := node.Propose("x").Wait(ctx); err != raft.ErrNotLeader {
+			t.Fatalf("Expected ErrNotLeader from follower, got %v", err)
+		}
+	}
+}
+
+// Запись, которую отрезанный лидер не смог закоммитить, завершается ошибкой,
+// а не висит до таймаута клиента.
+func TestProposeFailsOnLeadershipLoss(t *testing.T) {
+	cluster := newTestCluster(t, 3)
+	leader, _ := cluster.leader(t)
+	for i, node := range cluster.nodes {
+		if node == leader {
+			cluster.isolate(i + 1)
+		}
+	}
+
+	future := leader.Propose(kvstore.Command{Op: kvstore.OpSet, Key: "k", Value: "v"})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := future.Wait(ctx); err != raft.ErrLeadershipLost {
+		t.Fatalf("Expected ErrLeadershipLost, got %v", err)
+	}
+
+	// Новый лидер без второго узла не соберёт кворум
+	next, _ := cluster.leader(t)
+	for i, node := range cluster.nodes {
+		if node != next && node != leader {
+			cluster.isolate(i + 1)
+		}
+	}
+	short, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := next.Propose("x").Wait(short); err != raft.ErrProposalTimeout {
+		t.Fatalf("Expected ErrProposalTimeout, got %v", err)
+	}
+}
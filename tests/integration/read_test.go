@@ -0,0 +1,94 @@
+package integration
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"raft-kv-store/pkg/kvstore"
+	"raft-kv-store/pkg/raft"
+)
+
+var linearizable = kvstore.ReadOptions{Consistency: kvstore.ConsistencyLinearizable}
+
+// Отрезанный старый лидер не отдаёт линеаризуемое чтение, хотя его
+// локальное состояние ещё содержит старое значение.
+func TestLinearizableReadRejectsStaleLeader(t *testing.T) {
+	cluster := newTestCluster(t, 3)
+	old, oldStore := cluster.leader(t)
+	if err := oldStore.Put("k", "v1"); err != nil {
+		t.Fatalf("Failed to put: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if kv, err := oldStore.GetConsistent(ctx, "k", linearizable); err != nil || kv.Value != "v1" {
+		t.Fatalf("Expected v1, got %q, %v", kv.Value, err)
+	}
+	for i, node := range cluster.nodes {
+		if node != old {
+			if _, err := cluster.stores[i].GetConsistent(ctx, "k", linearizable); err != raft.ErrNotLeader {
+				t.Fatalf("Expected ErrNotLeader on follower, got %v", err)
+			}
+		}
+	}
+
+	for i, node := range cluster.nodes {
+		if node == old {
+			cluster.isolate(i + 1)
+		}
+	}
+	readCtx, readCancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer readCancel()
+	if kv, err := oldStore.GetConsistent(readCtx, "k", linearizable); err == nil {
+		t.Fatalf("Isolated leader served a linearizable read: %q", kv.Value)
+	}
+
+	_, store := cluster.leader(t)
+	if err := store.Put("k", "v2"); err != nil {
+		t.Fatalf("Failed to put on new leader: %v", err)
+	}
+	if kv, err := store.GetConsistent(ctx, "k", linearizable); err != nil || kv.Value != "v2" {
+		t.Fatalf("Expected v2 from new leader, got %q, %v", kv.Value, err)
+	}
+}
+
+// Lease старого лидера истекает раньше, чем остальные узлы выберут нового:
+// после записи на новом лидере старый не отдаёт lease-чтением прежнее
+// значение.
+func TestLeaseReadAcrossLeaderChange(t *testing.T) {
+	cluster := newTestCluster(t, 3)
+	old, oldStore := cluster.leader(t)
+	if err := oldStore.Put("k", "v1"); err != nil {
+		t.Fatalf("Failed to put: %v", err)
+	}
+
+	lease := kvstore.ReadOptions{Consistency: kvstore.ConsistencyLease}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	// Первые acks приходят с heartbeat-ами уже после выборов
+	waitFor(t, "leader lease", func() bool {
+		old.LeaseReadStats()
+		kv, err := oldStore.GetConsistent(ctx, "k", lease)
+		return err == nil && kv.Value == "v1" && old.LeaseReadStats().Valid == 1
+	})
+
+	for i, node := range cluster.nodes {
+		if node == old {
+			cluster.isolate(i + 1)
+		}
+	}
+	_, store := cluster.leader(t)
+	if err := store.Put("k", "v2"); err != nil {
+		t.Fatalf("Failed to put on new leader: %v", err)
+	}
+
+	readCtx, readCancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer readCancel()
+	if kv, err := oldStore.GetConsistent(readCtx, "k", lease); err == nil {
+		t.Fatalf("Old leader served a lease read after a new leader wrote: %q", kv.Value)
+	}
+	if kv, err := store.GetConsistent(ctx, "k", lease); err != nil || kv.Value != "v2" {
+		t.Fatalf("Expected v2 from new leader, got %q, %v", kv.Value, err)
+	}
+}
@@ -0,0 +1,197 @@
+package integration
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"raft-kv-store/pkg/raft"
+	"raft-kv-store/pkg/recipes"
+)
+
+func TestMutexFencingTokens(t *testing.T) {
+	cluster := newTestCluster(t, 3)
+	_, store := cluster.leader(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	s1, err := recipes.NewSession(ctx, store, 5*time.Second)
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	s2, err := recipes.NewSession(ctx, store, 5*time.Second)
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	m1 := recipes.NewMutex(s1, "jobs")
+	if err := m1.Lock(ctx); err != nil {
+		t.Fatalf("Failed to lock: %v", err)
+	}
+	first := m1.Token()
+
+	m2 := recipes.NewMutex(s2, "jobs")
+	locked := make(chan error, 1)
+	go func() { locked <- m2.Lock(ctx) }()
+
+	select {
+	case <-locked:
+		t.Fatalf("Second session acquired a held mutex")
+	case <-time.After(time.Second):
+	}
+
+	if err := m1.Unlock(ctx); err != nil {
+		t.Fatalf("Failed to unlock: %v", err)
+	}
+	if err := <-locked; err != nil {
+		t.Fatalf("Second lock failed: %v", err)
+	}
+	if m2.Token() <= first {
+		t.Errorf("Expected fencing token to grow, got %d after %d", m2.Token(), first)
+	}
+}
+
+func TestSemaphoreLimit(t *testing.T) {
+	cluster := newTestCluster(t, 3)
+	_, store := cluster.leader(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	sems := make([]*recipes.Semaphore, 3)
+	for i := range sems {
+		session, err := recipes.NewSession(ctx, store, 5*time.Second)
+		if err != nil {
+			t.Fatalf("Failed to create session: %v", err)
+		}
+		sems[i] = recipes.NewSemaphore(session, "workers", 2)
+	}
+
+	for _, sem := range sems[:2] {
+		if err := sem.Acquire(ctx); err != nil {
+			t.Fatalf("Failed to acquire: %v", err)
+		}
+	}
+
+	acquired := make(chan error, 1)
+	go func() { acquired <- sems[2].Acquire(ctx) }()
+
+	select {
+	case <-acquired:
+		t.Fatalf("Semaphore admitted more than its limit")
+	case <-time.After(time.Second):
+	}
+
+	if err := sems[0].Release(ctx); err != nil {
+		t.Fatalf("Failed to release: %v", err)
+	}
+	if err := <-acquired; err != nil {
+		t.Fatalf("Third acquire failed: %v", err)
+	}
+}
+
+func TestElectionFailoverOnSessionLoss(t *testing.T) {
+	cluster := newTestCluster(t, 3)
+	_, store := cluster.leader(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	s1, err := recipes.NewSession(ctx, store, 5*time.Second)
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	s2, err := recipes.NewSession(ctx, store, 5*time.Second)
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	observed := recipes.ObserveElection(store, "scheduler").Observe(ctx)
+
+	if err := recipes.NewElection(s1, "scheduler").Campaign(ctx, "node-a"); err != nil {
+		t.Fatalf("Campaign failed: %v", err)
+	}
+	campaigned := make(chan error, 1)
+	go func() { campaigned <- recipes.NewElection(s2, "scheduler").Campaign(ctx, "node-b") }()
+
+	if leader := <-observed; leader.Value != "node-a" {
+		t.Fatalf("Expected node-a to lead, got %q", leader.Value)
+	}
+
+	// Потеря сессии отзывает аренду и освобождает лидерство
+	if err := s1.Close(ctx); err != nil {
+		t.Fatalf("Failed to close session: %v", err)
+	}
+	if err := <-campaigned; err != nil {
+		t.Fatalf("Second campaign failed: %v", err)
+	}
+	if leader := <-observed; leader.Value != "node-b" {
+		t.Errorf("Expected node-b to lead, got %q", leader.Value)
+	}
+}
+
+// Ожидающий в очереди узнаёт об истечении своей аренды, а не получает
+// блокировку без ключа.
+func TestMutexWaiterSessionExpired(t *testing.T) {
+	cluster := newTestCluster(t, 3)
+	_, store := cluster.leader(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	s1, err := recipes.NewSession(ctx, store, 5*time.Second)
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	defer s1.Close(ctx)
+	if err := recipes.NewMutex(s1, "jobs").Lock(ctx); err != nil {
+		t.Fatalf("Lock failed: %v", err)
+	}
+
+	lease, err := store.Grant(ctx, 5*time.Second)
+	if err != nil {
+		t.Fatalf("Grant failed: %v", err)
+	}
+	m2 := recipes.NewMutex(recipes.SessionFromLease(store, lease), "jobs")
+	locked := make(chan error, 1)
+	go func() { locked <- m2.Lock(ctx) }()
+
+	waitFor(t, "second waiter to queue", func() bool {
+		page := store.Prefix(recipes.LockPrefix("jobs"), 10)
+		return len(page.Items) == 2
+	})
+	if err := store.Revoke(ctx, lease); err != nil {
+		t.Fatalf("Revoke failed: %v", err)
+	}
+	if err := <-locked; err != recipes.ErrSessionExpired {
+		t.Fatalf("Expected ErrSessionExpired, got %v", err)
+	}
+}
+
+// Сессия на узле, переставшем быть лидером, больше не продлевается, и
+// Done сообщает об этом.
+func TestSessionDoneOnLeadershipLoss(t *testing.T) {
+	cluster := newTestCluster(t, 3)
+	leader, store := cluster.leader(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	session, err := recipes.NewSession(ctx, store, 3*time.Second)
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	defer session.Close(ctx)
+
+	if err := leader.TransferLeadership(ctx, 0); err != nil {
+		t.Fatalf("Transfer failed: %v", err)
+	}
+	select {
+	case <-session.Done():
+	case <-ctx.Done():
+		t.Fatalf("Session did not report the lost keepalive")
+	}
+	if err := session.Err(); err != raft.ErrNotLeader {
+		t.Fatalf("Expected ErrNotLeader, got %v", err)
+	}
+}
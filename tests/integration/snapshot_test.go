@@ -0,0 +1,86 @@
+package integration
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"raft-kv-store/pkg/raft"
+)
+
+// Фоловер отстал ровно до первой записи, оставшейся в логе лидера после
+// снапшота с хвостом: терм предыдущей записи лидеру уже неизвестен, и он
+// должен отправить снапшот.
+func TestLaggingFollowerAtFirstLogIndex(t *testing.T) {
+	const (
+		caughtUp  = 10
+		threshold = 20
+	)
+	cluster := newSnapshotCluster(t, 3, raft.SnapshotConfig{
+		Threshold:    threshold,
+		TrailingLogs: threshold - caughtUp,
+	})
+	leader, store := cluster.leader(t)
+
+	leaderID, followerID := 0, 0
+	for i, node := range cluster.nodes {
+		if node == leader {
+			leaderID = i + 1
+		} else if followerID == 0 {
+			followerID = i + 1
+		}
+	}
+
+	// Каждая запись коммитит и все предыдущие, включая пустую запись лидера
+	for leader.GetClusterStatus().CommitIndex < caughtUp {
+		if err := store.Put("before", "v"); err != nil {
+			t.Fatalf("Failed to put: %v", err)
+		}
+	}
+	waitFor(t, "followers to catch up", func() bool {
+		status := leader.GetClusterStatus()
+		if status.CommitIndex != caughtUp {
+			return false
+		}
+		for _, node := range status.Nodes {
+			if node.Lag != 0 {
+				return false
+			}
+		}
+		return true
+	})
+
+	// Пока фоловер отрезан, лидер доходит до порога и обрезает лог по
+	// caughtUp: его nextIndex для фоловера совпадает с первой записью лога
+	cluster.isolate(followerID)
+	for i := caughtUp; i < threshold; i++ {
+		if err := store.Put(fmt.Sprintf("key-%d", i), "v"); err != nil {
+			t.Fatalf("Failed to put: %v", err)
+		}
+	}
+	waitFor(t, "leader snapshot", func() bool {
+		files, _ := filepath.Glob(filepath.Join(cluster.nodeDir(leaderID), "snapshots", "*.snap"))
+		return len(files) > 0
+	})
+
+	cluster.heal()
+	last := fmt.Sprintf("key-%d", threshold-1)
+	waitFor(t, "follower to install the snapshot", func() bool {
+		_, err := cluster.stores[followerID-1].Get(last)
+		return err == nil
+	})
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(15 * time.Second)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	t.Fatalf("Timed out waiting for %s", what)
+}
@@ -0,0 +1,146 @@
+package integration
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"raft-kv-store/pkg/raft"
+)
+
+func TestTransferLeadership(t *testing.T) {
+	cluster := newTestCluster(t, 3)
+	leader, store := cluster.leader(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, err := store.Propose(ctx, "key", "value"); err != nil {
+		t.Fatalf("Failed to put: %v", err)
+	}
+
+	target := 0
+	for i, node := range cluster.nodes {
+		if node != leader {
+			target = i + 1
+			break
+		}
+	}
+
+	start := time.Now()
+	if err := leader.TransferLeadership(ctx, target); err != nil {
+		t.Fatalf("Failed to transfer leadership: %v", err)
+	}
+	// Передача не ждёт таймаута выборов
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Transfer took %v", elapsed)
+	}
+
+	next, store := cluster.leader(t)
+	if next != cluster.nodes[target-1] {
+		t.Fatalf("Expected node %d to become leader", target)
+	}
+	// Новый лидер мог ещё не применить запись: читаем после ReadIndex
+	for {
+		_, err := next.ReadIndex(ctx)
+		if err == nil {
+			break
+		}
+		if err != raft.ErrReadIndexNotReady || ctx.Err() != nil {
+			t.Fatalf("ReadIndex failed: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if v, err := store.Get("key"); err != nil || v != "value" {
+		t.Fatalf("Expected new leader to have the key, got %q, %v", v, err)
+	}
+}
+
+// Пока лидер догоняет цель передачи, lease-чтения идут через ReadIndex.
+func TestLeaseReadDuringTransfer(t *testing.T) {
+	cluster := newTestCluster(t, 3)
+	leader, store := cluster.leader(t)
+
+	target := 0
+	for i, node := range cluster.nodes {
+		if node != leader {
+			target = i + 1
+			break
+		}
+	}
+
+	// Отрезанная цель отстаёт, и передача застревает на догоне
+	cluster.isolate(target)
+	for i := 0; i < 3; i++ {
+		if err := store.Put(fmt.Sprintf("key-%d", i), "v"); err != nil {
+			t.Fatalf("Failed to put: %v", err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	transferred := make(chan error, 1)
+	go func() { transferred <- leader.TransferLeadership(ctx, target) }()
+
+	waitFor(t, "transfer to start", func() bool {
+		return store.Put("probe", "v") == raft.ErrTransferInProgress
+	})
+	leader.LeaseReadStats()
+	if _, err := leader.LeaseRead(context.Background()); err != nil {
+		t.Fatalf("LeaseRead failed: %v", err)
+	}
+	if stats := leader.LeaseReadStats(); stats.Valid != 0 {
+		t.Errorf("Expected no lease reads during transfer, got %+v", stats)
+	}
+
+	if err := <-transferred; err != raft.ErrTransferTimeout {
+		t.Errorf("Expected ErrTransferTimeout, got %v", err)
+	}
+}
+
+// Цель могла получить TimeoutNow и начать выборы позже, поэтому после
+// неудачной передачи lease не действует ещё полный таймаут выборов.
+func TestLeaseReadAfterFailedTimeoutNow(t *testing.T) {
+	cluster := newTestCluster(t, 3)
+	leader, store := cluster.leader(t)
+
+	target := 0
+	for i, node := range cluster.nodes {
+		if node != leader {
+			target = i + 1
+			break
+		}
+	}
+
+	// Цель догнала лидера до изоляции: TimeoutNow уходит сразу и теряется
+	if err := store.Put("key", "v"); err != nil {
+		t.Fatalf("Failed to put: %v", err)
+	}
+	waitFor(t, "target to catch up", func() bool {
+		_, err := cluster.stores[target-1].Get("key")
+		return err == nil
+	})
+	cluster.isolate(target)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := leader.TransferLeadership(ctx, target); err == nil {
+		t.Fatalf("Expected transfer to an isolated node to fail")
+	}
+
+	leader.LeaseReadStats()
+	if _, err := leader.LeaseRead(context.Background()); err != nil {
+		t.Fatalf("LeaseRead failed: %v", err)
+	}
+	if stats := leader.LeaseReadStats(); stats.Valid != 0 {
+		t.Errorf("Expected no lease reads right after TimeoutNow, got %+v", stats)
+	}
+
+	waitFor(t, "lease reads to resume", func() bool {
+		if _, err := leader.LeaseRead(context.Background()); err != nil {
+			return false
+		}
+		return leader.LeaseReadStats().Valid > 0
+	})
+}
@@ -0,0 +1,106 @@
+package integration
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"raft-kv-store/pkg/kvstore"
+	pb "raft-kv-store/proto"
+)
+
+func postTxn(t *testing.T, handler http.Handler, body string) kvstore.TxnResult {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("POST", "/txn", strings.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("POST /txn: expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	var result kvstore.TxnResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+		t.Fatalf("Invalid txn response %s: %v", rec.Body, err)
+	}
+	return result
+}
+
+func TestHTTPTxnBranches(t *testing.T) {
+	cluster := newTestCluster(t, 3)
+	router := leaderRouter(t, cluster)
+	_, store := cluster.leader(t)
+
+	if err := store.Put("a", "1"); err != nil {
+		t.Fatalf("Failed to put: %v", err)
+	}
+
+	// Условие выполняется: применяется success целиком
+	result := postTxn(t, router, `{
+		"compare": [{"key": "a", "target": "value", "result": "equal", "value": "1"}],
+		"success": [{"op": "SET", "key": "b", "value": "2"}, {"op": "DELETE", "key": "a"}],
+		"failure": [{"op": "SET", "key": "c", "value": "3"}]
+	}`)
+	if !result.Succeeded || len(result.Results) != 2 {
+		t.Fatalf("Expected the success branch, got %+v", result)
+	}
+	set, del := result.Results[0], result.Results[1]
+	if !del.Existed || del.Version != set.Version {
+		t.Fatalf("Expected the delete at the txn revision %d, got %+v", set.Version, del)
+	}
+	if _, err := store.GetKV("a"); err != kvstore.ErrKeyNotFound {
+		t.Fatalf("Expected a to be deleted, got %v", err)
+	}
+	if _, err := store.GetKV("c"); err != kvstore.ErrKeyNotFound {
+		t.Fatalf("Expected the failure branch not to run, got %v", err)
+	}
+
+	// Условие не выполняется: success не применяется, failure читает ключ
+	result = postTxn(t, router, `{
+		"compare": [{"key": "a", "target": "version", "result": "greater", "version": 0}],
+		"success": [{"op": "SET", "key": "b", "value": "changed"}],
+		"failure": [{"op": "GET", "key": "b"}, {"op": "DELETE", "key": "missing"}]
+	}`)
+	if result.Succeeded || len(result.Results) != 2 {
+		t.Fatalf("Expected the failure branch, got %+v", result)
+	}
+	if get := result.Results[0]; get.Value != "2" || get.Version != set.Version {
+		t.Fatalf("Expected GET of b=2 at %d, got %+v", set.Version, get)
+	}
+	if miss := result.Results[1]; miss.Existed || miss.Version != 0 {
+		t.Fatalf("Expected delete of an absent key to report no version, got %+v", miss)
+	}
+	if kv, _ := store.GetKV("b"); kv.Value != "2" {
+		t.Fatalf("Expected b unchanged, got %q", kv.Value)
+	}
+}
+
+func TestKeyValueServiceTxnLease(t *testing.T) {
+	cluster := newTestCluster(t, 3)
+	leader, store := cluster.leader(t)
+
+	var kv pb.KeyValueServiceClient
+	for i, node := range cluster.nodes {
+		if node == leader {
+			kv = dialKV(t, cluster.addrs[i])
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	grant, err := kv.LeaseGrant(ctx, &pb.LeaseGrantRequest{Ttl: 10})
+	if err != nil {
+		t.Fatalf("LeaseGrant failed: %v", err)
+	}
+
+	resp, err := kv.Txn(ctx, &pb.TxnRequest{
+		Success: []*pb.RequestOp{{Op: kvstore.OpSet, Key: "k", Value: "v", Lease: grant.Id}},
+	})
+	if err != nil || !resp.Succeeded {
+		t.Fatalf("Txn failed: %+v, %v", resp, err)
+	}
+	if got, _ := store.GetKV("k"); got.Lease != int(grant.Id) {
+		t.Fatalf("Expected key attached to lease %d, got %d", grant.Id, got.Lease)
+	}
+}
@@ -0,0 +1,73 @@
+package tests
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"raft-kv-store/pkg/kvstore"
+	"raft-kv-store/pkg/raft"
+)
+
+func TestLeaseRevokeDeletesAttachedKeys(t *testing.T) {
+	store := kvstore.NewStore(raft.NewRaftNode(1, []string{}))
+
+	store.ApplyLog(1, kvstore.Command{Op: kvstore.OpLeaseGrant, TTL: 10 * time.Second})
+	store.ApplyLog(2, kvstore.Command{Op: kvstore.OpSet, Key: "svc/a", Value: "up", Lease: 1})
+	store.ApplyLog(3, kvstore.Command{Op: kvstore.OpSet, Key: "svc/b", Value: "up", Lease: 1})
+	store.ApplyLog(4, kvstore.Command{Op: kvstore.OpSet, Key: "static", Value: "x"})
+
+	if res := store.ApplyLog(5, kvstore.Command{Op: kvstore.OpSet, Key: "c", Value: "v", Lease: 42}); res != kvstore.ErrLeaseNotFound {
+		t.Errorf("Expected ErrLeaseNotFound, got %v", res)
+	}
+
+	// Аренда и привязки должны пережить снапшот
+	data, err := store.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+	restored := kvstore.NewStore(raft.NewRaftNode(2, []string{}))
+	if err := restored.Restore(bytes.NewReader(data)); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+
+	lease, err := restored.TimeToLive(1)
+	if err != nil || len(lease.Keys) != 2 {
+		t.Fatalf("Expected lease with 2 keys, got %+v (%v)", lease, err)
+	}
+
+	restored.ApplyLog(6, kvstore.Command{Op: kvstore.OpLeaseRevoke, Lease: 1})
+
+	for _, key := range []string{"svc/a", "svc/b"} {
+		if _, err := restored.Get(key); err != kvstore.ErrKeyNotFound {
+			t.Errorf("Expected %s to be deleted with the lease, got %v", key, err)
+		}
+	}
+	if _, err := restored.Get("static"); err != nil {
+		t.Errorf("Expected static key to survive, got %v", err)
+	}
+}
+
+// Транзакция с неизвестной арендой не применяется, а не пишет ключ без
+// аренды.
+func TestTxnWithUnknownLeaseFails(t *testing.T) {
+	store := kvstore.NewStore(raft.NewRaftNode(1, []string{}))
+	defer store.Close()
+
+	store.ApplyLog(1, kvstore.Command{Op: kvstore.OpLeaseGrant, TTL: 10 * time.Second})
+	res := store.ApplyLog(2, kvstore.Command{
+		Op: kvstore.OpTxn,
+		Success: []kvstore.Command{
+			{Op: kvstore.OpSet, Key: "lock/a", Value: "x", Lease: 1},
+			{Op: kvstore.OpSet, Key: "lock/b", Value: "x", Lease: 42},
+		},
+	})
+	if res != kvstore.ErrLeaseNotFound {
+		t.Fatalf("Expected ErrLeaseNotFound, got %v", res)
+	}
+	for _, key := range []string{"lock/a", "lock/b"} {
+		if _, err := store.Get(key); err != kvstore.ErrKeyNotFound {
+			t.Errorf("Expected %s to be absent, got %v", key, err)
+		}
+	}
+}
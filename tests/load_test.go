@@ -16,6 +16,12 @@ func TestLoad(t *testing.T) {
 	node := raft.NewRaftNode(1, []string{})
 	store := kvstore.NewStore(node)
 	go node.Run()
+	defer node.Stop()
+
+	// Одиночный узел становится лидером после первого таймаута выборов
+	for !node.IsLeader() {
+		time.Sleep(100 * time.Millisecond)
+	}
 
 	// Параметры теста
 	const (
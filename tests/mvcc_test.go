@@ -0,0 +1,82 @@
+package tests
+
+import (
+	"testing"
+
+	"raft-kv-store/pkg/kvstore"
+	"raft-kv-store/pkg/raft"
+)
+
+func TestHistoricalReadsAndCompaction(t *testing.T) {
+	store := kvstore.NewStore(raft.NewRaftNode(1, []string{}))
+
+	store.ApplyLog(1, kvstore.Command{Op: kvstore.OpSet, Key: "a", Value: "v1"})
+	store.ApplyLog(2, kvstore.Command{Op: kvstore.OpSet, Key: "b", Value: "x"})
+	store.ApplyLog(3, kvstore.Command{Op: kvstore.OpSet, Key: "a", Value: "v2"})
+	store.ApplyLog(4, kvstore.Command{Op: kvstore.OpDelete, Key: "b"})
+
+	kv, err := store.GetAt("a", 2)
+	if err != nil || kv.Value != "v1" || kv.Version != 1 {
+		t.Errorf("Expected v1 at revision 2, got %+v (%v)", kv, err)
+	}
+
+	page, err := store.RangeAt("", "", 10, 3)
+	if err != nil {
+		t.Fatalf("RangeAt failed: %v", err)
+	}
+	if len(page.Items) != 2 || page.Items[1].Key != "b" {
+		t.Errorf("Expected a and b at revision 3, got %+v", page.Items)
+	}
+
+	store.ApplyLog(5, kvstore.Command{Op: kvstore.OpCompact, Version: 4})
+
+	if _, err := store.GetAt("a", 2); err != kvstore.ErrCompacted {
+		t.Errorf("Expected ErrCompacted, got %v", err)
+	}
+	kv, err = store.GetAt("a", 4)
+	if err != nil || kv.Value != "v2" {
+		t.Errorf("Expected v2 at revision 4, got %+v (%v)", kv, err)
+	}
+	if _, err := store.GetAt("b", 4); err != kvstore.ErrKeyNotFound {
+		t.Errorf("Expected ErrKeyNotFound for deleted key, got %v", err)
+	}
+}
+
+// Каждая операция транзакции над одним ключом даёт своё событие, в том
+// числе после компакции на ревизии транзакции.
+func TestTxnKeepsEveryEventOfAKey(t *testing.T) {
+	store := kvstore.NewStore(raft.NewRaftNode(1, []string{}))
+
+	store.ApplyLog(1, kvstore.Command{Op: kvstore.OpSet, Key: "k", Value: "v0"})
+	store.ApplyLog(2, kvstore.Command{Op: kvstore.OpTxn, Success: []kvstore.Command{
+		{Op: kvstore.OpSet, Key: "k", Value: "v1"},
+		{Op: kvstore.OpDelete, Key: "k"},
+		{Op: kvstore.OpSet, Key: "k", Value: "v2"},
+	}})
+
+	want := []kvstore.Event{
+		{Type: kvstore.EventPut, Key: "k", Value: "v1", Revision: 2},
+		{Type: kvstore.EventDelete, Key: "k", Revision: 2},
+		{Type: kvstore.EventPut, Key: "k", Value: "v2", Revision: 2},
+	}
+	check := func(stage string) {
+		w, err := store.Watch("k", false, 2)
+		if err != nil {
+			t.Fatalf("%s: Watch failed: %v", stage, err)
+		}
+		defer w.Close()
+		for _, expected := range want {
+			if ev := <-w.Events(); ev != expected {
+				t.Errorf("%s: expected %+v, got %+v", stage, expected, ev)
+			}
+		}
+	}
+
+	check("before compaction")
+	if kv, err := store.GetAt("k", 2); err != nil || kv.Value != "v2" {
+		t.Errorf("Expected v2 at revision 2, got %+v (%v)", kv, err)
+	}
+
+	store.ApplyLog(3, kvstore.Command{Op: kvstore.OpCompact, Version: 2})
+	check("after compaction")
+}
@@ -0,0 +1,26 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"raft-kv-store/pkg/kvstore"
+)
+
+func TestParseStaleMaxLag(t *testing.T) {
+	opts, err := kvstore.ParseReadOptions("stale", "100")
+	if err != nil || opts.MaxLagEntries != 100 {
+		t.Fatalf("Expected 100 entries, got %+v, %v", opts, err)
+	}
+	opts, err = kvstore.ParseReadOptions("stale", "500ms")
+	if err != nil || opts.MaxLagTime != 500*time.Millisecond {
+		t.Fatalf("Expected 500ms, got %+v, %v", opts, err)
+	}
+
+	// Нулевая граница не должна превращаться в чтение без ограничения
+	for _, maxLag := range []string{"0", "0s", "-1", ""} {
+		if _, err := kvstore.ParseReadOptions("stale", maxLag); err != kvstore.ErrInvalidMaxLag {
+			t.Errorf("max_lag=%q: expected ErrInvalidMaxLag, got %v", maxLag, err)
+		}
+	}
+}
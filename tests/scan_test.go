@@ -0,0 +1,52 @@
+package tests
+
+import (
+	"fmt"
+	"testing"
+
+	"raft-kv-store/pkg/kvstore"
+	"raft-kv-store/pkg/raft"
+)
+
+func TestPrefixScanPagination(t *testing.T) {
+	store := kvstore.NewStore(raft.NewRaftNode(1, []string{}))
+
+	// Ключи применяются напрямую, минуя консенсус
+	index := 1
+	for _, prefix := range []string{"app/", "svc/", "svd/"} {
+		for i := 0; i < 5; i++ {
+			store.ApplyLog(index, kvstore.Command{
+				Op:    kvstore.OpSet,
+				Key:   fmt.Sprintf("%s%02d", prefix, 4-i),
+				Value: "v",
+			})
+			index++
+		}
+	}
+
+	var keys []string
+	page := store.Prefix("svc/", 2)
+	for {
+		for _, kv := range page.Items {
+			keys = append(keys, kv.Key)
+		}
+		if page.Next == "" {
+			break
+		}
+		start, err := kvstore.DecodeToken(page.Next)
+		if err != nil {
+			t.Fatalf("Failed to decode token: %v", err)
+		}
+		page = store.Range(start, kvstore.PrefixEnd("svc/"), 2)
+	}
+
+	expected := []string{"svc/00", "svc/01", "svc/02", "svc/03", "svc/04"}
+	if fmt.Sprint(keys) != fmt.Sprint(expected) {
+		t.Fatalf("Expected %v, got %v", expected, keys)
+	}
+
+	store.ApplyLog(index, kvstore.Command{Op: kvstore.OpDelete, Key: "svc/02"})
+	if page := store.Prefix("svc/", 0); len(page.Items) != 4 {
+		t.Fatalf("Expected 4 keys after delete, got %d", len(page.Items))
+	}
+}
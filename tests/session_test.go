@@ -0,0 +1,123 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"raft-kv-store/pkg/kvstore"
+	"raft-kv-store/pkg/raft"
+)
+
+func TestRetriedCommandAppliedOnce(t *testing.T) {
+	store := kvstore.NewStore(raft.NewRaftNode(1, []string{}))
+
+	store.ApplyLog(1, kvstore.Command{Op: kvstore.OpSet, Key: "k", Value: "a"})
+
+	cas := kvstore.Command{
+		Op:        kvstore.OpCompareAndSwap,
+		Key:       "k",
+		Value:     "b",
+		PrevValue: "a",
+		ClientID:  "client-1",
+		Seq:       1,
+		Timestamp: 100,
+	}
+	first := store.ApplyLog(2, cas)
+
+	// Повтор после таймаута попадает в лог второй раз, в том числе на узле,
+	// восстановленном из снапшота
+	data, err := store.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+	restored := kvstore.NewStore(raft.NewRaftNode(2, []string{}))
+	if err := restored.Restore(bytes.NewReader(data)); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+
+	retry := restored.ApplyLog(3, cas)
+	if retry != first {
+		t.Errorf("Expected cached response %+v, got %+v", first, retry)
+	}
+	kv, _ := restored.GetKV("k")
+	if kv.Version != 2 {
+		t.Errorf("Expected retry not to be applied, version is %d", kv.Version)
+	}
+
+	restored.ApplyLog(4, kvstore.Command{Op: kvstore.OpExpireSessions, Timestamp: 200})
+	if res := restored.ApplyLog(5, cas); res == first {
+		t.Errorf("Expected expired session not to return the cached response")
+	}
+}
+
+// Повтор запроса получает версию, записанную первой попыткой, а не индекс
+// своей записи в логе.
+func TestRetriedRequestReturnsOriginalVersion(t *testing.T) {
+	node := raft.NewRaftNode(1, []string{})
+	store := kvstore.NewStore(node)
+	defer store.Close()
+	go node.Run()
+	defer node.Stop()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for !node.IsLeader() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Node did not become leader")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ctx = kvstore.WithRequestID(ctx, "client-1", 1)
+
+	first, err := store.Propose(ctx, "k", "v")
+	if err != nil {
+		t.Fatalf("Propose failed: %v", err)
+	}
+	retry, err := store.Propose(ctx, "k", "v")
+	if err != nil {
+		t.Fatalf("Retry failed: %v", err)
+	}
+	if retry != first {
+		t.Errorf("Expected retry to return version %d, got %d", first, retry)
+	}
+	if kv, _ := store.GetKV("k"); kv.Version != first {
+		t.Errorf("Expected key version %d, got %d", first, kv.Version)
+	}
+}
+
+// Повтор LEASE_GRANT не создаёт вторую аренду и возвращает ID первой, в том
+// числе после восстановления из снапшота.
+func TestRetriedGrantReturnsOriginalLease(t *testing.T) {
+	store := kvstore.NewStore(raft.NewRaftNode(1, []string{}))
+
+	grant := kvstore.Command{
+		Op:        kvstore.OpLeaseGrant,
+		TTL:       time.Minute,
+		ClientID:  "client-1",
+		Seq:       1,
+		Timestamp: 100,
+	}
+	if id := store.ApplyLog(2, grant); id != 2 {
+		t.Fatalf("Expected lease 2, got %v", id)
+	}
+
+	data, err := store.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+	restored := kvstore.NewStore(raft.NewRaftNode(2, []string{}))
+	if err := restored.Restore(bytes.NewReader(data)); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+
+	if id := restored.ApplyLog(3, grant); id != 2 {
+		t.Errorf("Expected retry to return lease 2, got %v", id)
+	}
+	if _, err := restored.TimeToLive(3); err != kvstore.ErrLeaseNotFound {
+		t.Errorf("Expected no lease created by the retry, got %v", err)
+	}
+}
@@ -0,0 +1,110 @@
+package tests
+
+import (
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"raft-kv-store/pkg/raft"
+)
+
+func TestCommitSinkRejectsStaleSnapshot(t *testing.T) {
+	// Снапшот с индексом 3 готовит другой узел
+	srcNode := raft.NewRaftNode(1, nil)
+	defer srcNode.Stop()
+	srcDir := t.TempDir()
+	src, err := raft.NewSnapshotStore(srcNode, srcDir, false, raft.DefaultSnapshotConfig())
+	if err != nil {
+		t.Fatalf("Failed to open snapshot store: %v", err)
+	}
+	if err := src.CreateSnapshot(3, 1, raft.Configuration{}, []byte("old")); err != nil {
+		t.Fatalf("CreateSnapshot failed: %v", err)
+	}
+	files, _ := filepath.Glob(filepath.Join(srcDir, "snapshots", "*.snap"))
+	if len(files) != 1 {
+		t.Fatalf("Expected 1 snapshot, got %d", len(files))
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatalf("Failed to read snapshot: %v", err)
+	}
+
+	node := raft.NewRaftNode(2, nil)
+	defer node.Stop()
+	dir := t.TempDir()
+	store, err := raft.NewSnapshotStore(node, dir, false, raft.DefaultSnapshotConfig())
+	if err != nil {
+		t.Fatalf("Failed to open snapshot store: %v", err)
+	}
+	if err := store.CreateSnapshot(5, 1, raft.Configuration{}, []byte("new")); err != nil {
+		t.Fatalf("CreateSnapshot failed: %v", err)
+	}
+
+	sink, err := store.CreateSink(3, 1)
+	if err != nil {
+		t.Fatalf("CreateSink failed: %v", err)
+	}
+	if _, err := sink.Write(data); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	checksum := crc32.Checksum(data, crc32.MakeTable(crc32.Castagnoli))
+	if _, err := store.CommitSink(sink, checksum); err != raft.ErrStaleSnapshot {
+		t.Fatalf("Expected ErrStaleSnapshot, got %v", err)
+	}
+
+	entries, _ := os.ReadDir(filepath.Join(dir, "snapshots"))
+	if len(entries) != 1 {
+		t.Fatalf("Expected only the newer snapshot on disk, got %d files", len(entries))
+	}
+	meta, file, err := store.OpenLatest()
+	if err != nil {
+		t.Fatalf("OpenLatest failed: %v", err)
+	}
+	file.Close()
+	if meta.Index != 5 {
+		t.Fatalf("Expected latest snapshot at index 5, got %d", meta.Index)
+	}
+}
+
+func TestRestoreKeepsTrailingLogs(t *testing.T) {
+	dir := t.TempDir()
+
+	logs, err := raft.NewFileStore(filepath.Join(dir, "logs"))
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	var entries []raft.LogEntry
+	for i := 1; i <= 20; i++ {
+		entries = append(entries, raft.LogEntry{Index: i, Term: 1, Command: "x"})
+	}
+	if err := logs.StoreLogs(entries); err != nil {
+		t.Fatalf("StoreLogs failed: %v", err)
+	}
+
+	// Снапшот на 15 остался от прошлого запуска, лог обрезать не успели
+	prev := raft.NewRaftNode(1, nil)
+	prevStore, err := raft.NewSnapshotStore(prev, dir, false, raft.DefaultSnapshotConfig())
+	if err != nil {
+		t.Fatalf("Failed to open snapshot store: %v", err)
+	}
+	if err := prevStore.CreateSnapshot(15, 1, raft.Configuration{}, nil); err != nil {
+		t.Fatalf("CreateSnapshot failed: %v", err)
+	}
+	prev.Stop()
+
+	node, err := raft.NewRaftNodeWithStores(1, nil, logs, logs)
+	if err != nil {
+		t.Fatalf("Failed to create node: %v", err)
+	}
+	defer node.Stop()
+	if _, err := raft.NewSnapshotStore(node, dir, false, raft.SnapshotConfig{TrailingLogs: 5}); err != nil {
+		t.Fatalf("Failed to load snapshot: %v", err)
+	}
+
+	first, _ := logs.FirstIndex()
+	last, _ := logs.LastIndex()
+	if first != 11 || last != 20 {
+		t.Fatalf("Expected log [11, 20] after restore, got [%d, %d]", first, last)
+	}
+}
@@ -0,0 +1,64 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"raft-kv-store/pkg/raft"
+)
+
+func TestFileStoreRecovery(t *testing.T) {
+	dir := t.TempDir()
+
+	store, err := raft.NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+
+	entries := []raft.LogEntry{
+		{Index: 1, Term: 1, Command: "a"},
+		{Index: 2, Term: 1, Command: "b"},
+		{Index: 3, Term: 2, Command: "c"},
+	}
+	if err := store.StoreLogs(entries); err != nil {
+		t.Fatalf("StoreLogs failed: %v", err)
+	}
+	if err := store.DeleteRange(3, 3); err != nil {
+		t.Fatalf("DeleteRange failed: %v", err)
+	}
+	if err := store.SetState(2, 3); err != nil {
+		t.Fatalf("SetState failed: %v", err)
+	}
+	store.Close()
+
+	// Имитация оборванной записи в хвосте сегмента
+	segments, _ := filepath.Glob(filepath.Join(dir, "*.wal"))
+	f, err := os.OpenFile(segments[len(segments)-1], os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatalf("Failed to open segment: %v", err)
+	}
+	f.Write([]byte{0, 0, 1, 0, 0xde, 0xad})
+	f.Close()
+
+	store, err = raft.NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	defer store.Close()
+
+	last, _ := store.LastIndex()
+	if last != 2 {
+		t.Fatalf("Expected last index 2, got %d", last)
+	}
+
+	var entry raft.LogEntry
+	if err := store.GetLog(2, &entry); err != nil || entry.Command != "b" {
+		t.Fatalf("Unexpected entry 2: %+v, %v", entry, err)
+	}
+
+	term, votedFor, _ := store.GetState()
+	if term != 2 || votedFor != 3 {
+		t.Fatalf("Expected term 2 votedFor 3, got %d %d", term, votedFor)
+	}
+}
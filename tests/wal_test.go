@@ -0,0 +1,210 @@
+package tests
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"raft-kv-store/pkg/raft"
+)
+
+func writeTestWAL(t *testing.T, dir string) string {
+	t.Helper()
+
+	store, err := raft.NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	entries := []raft.LogEntry{
+		{Index: 1, Term: 1, Command: "a"},
+		{Index: 2, Term: 1, Command: "b"},
+		{Index: 3, Term: 2, Command: "c"},
+	}
+	if err := store.StoreLogs(entries); err != nil {
+		t.Fatalf("StoreLogs failed: %v", err)
+	}
+	if err := store.SetState(2, 3); err != nil {
+		t.Fatalf("SetState failed: %v", err)
+	}
+	store.Close()
+
+	segments, _ := filepath.Glob(filepath.Join(dir, "*.wal"))
+	if len(segments) != 1 {
+		t.Fatalf("Expected 1 segment, got %d", len(segments))
+	}
+	return segments[0]
+}
+
+func TestWALTruncatesTornTail(t *testing.T) {
+	dir := t.TempDir()
+	segment := writeTestWAL(t, dir)
+
+	// Имитация записи, оборванной посреди payload
+	f, err := os.OpenFile(segment, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatalf("Failed to open segment: %v", err)
+	}
+	f.Write([]byte{0, 0, 1, 0, 0xde, 0xad, 0xbe, 0xef, 1, 2, 3})
+	f.Close()
+
+	store, err := raft.NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	defer store.Close()
+
+	last, _ := store.LastIndex()
+	if last != 3 {
+		t.Fatalf("Expected last index 3, got %d", last)
+	}
+	if err := store.StoreLogs([]raft.LogEntry{{Index: 4, Term: 2, Command: "d"}}); err != nil {
+		t.Fatalf("StoreLogs after recovery failed: %v", err)
+	}
+}
+
+func TestWALRejectsMidSegmentCorruption(t *testing.T) {
+	dir := t.TempDir()
+	segment := writeTestWAL(t, dir)
+
+	data, err := os.ReadFile(segment)
+	if err != nil {
+		t.Fatalf("Failed to read segment: %v", err)
+	}
+	// Порча payload первой записи: за ней лежат целые записи,
+	// обрезать такой сегмент нельзя
+	data[10] ^= 0xff
+	if err := os.WriteFile(segment, data, 0644); err != nil {
+		t.Fatalf("Failed to write segment: %v", err)
+	}
+
+	if _, err := raft.NewFileStore(dir); !errors.Is(err, raft.ErrCorruptWAL) {
+		t.Fatalf("Expected ErrCorruptWAL, got %v", err)
+	}
+
+	after, _ := os.ReadFile(segment)
+	if len(after) != len(data) {
+		t.Fatalf("Corrupt segment was truncated from %d to %d bytes", len(data), len(after))
+	}
+}
+
+func TestWALRejectsOversizedRecord(t *testing.T) {
+	dir := t.TempDir()
+	writeTestWAL(t, dir)
+
+	store, err := raft.NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	huge := strings.Repeat("x", 64<<20)
+	err = store.StoreLogs([]raft.LogEntry{{Index: 4, Term: 2, Command: huge}})
+	if !errors.Is(err, raft.ErrRecordTooLarge) {
+		t.Fatalf("Expected ErrRecordTooLarge, got %v", err)
+	}
+	store.Close()
+
+	store, err = raft.NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("Failed to reopen store after rejected record: %v", err)
+	}
+	defer store.Close()
+
+	last, _ := store.LastIndex()
+	if last != 3 {
+		t.Fatalf("Expected last index 3, got %d", last)
+	}
+}
+
+func TestWALReopenAfterCheckpoint(t *testing.T) {
+	dir := t.TempDir()
+
+	store, err := raft.NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	// 70 записей по 1 МБ не помещаются в один сегмент
+	value := strings.Repeat("x", 1<<20)
+	entries := make([]raft.LogEntry, 70)
+	for i := range entries {
+		entries[i] = raft.LogEntry{Index: i + 1, Term: 1, Command: value}
+	}
+	if err := store.StoreLogs(entries); err != nil {
+		t.Fatalf("StoreLogs failed: %v", err)
+	}
+	if err := store.SetState(3, 2); err != nil {
+		t.Fatalf("SetState failed: %v", err)
+	}
+
+	segments, _ := filepath.Glob(filepath.Join(dir, "*.wal"))
+	if len(segments) != 2 {
+		t.Fatalf("Expected 2 segments, got %d", len(segments))
+	}
+
+	// Первый сегмент ещё содержит живые записи: удалять и переписывать нечего
+	if err := store.DeleteRange(1, 10); err != nil {
+		t.Fatalf("DeleteRange failed: %v", err)
+	}
+	after, _ := filepath.Glob(filepath.Join(dir, "*.wal"))
+	if len(after) != 2 || after[0] != segments[0] {
+		t.Fatalf("Expected segments %v to stay, got %v", segments, after)
+	}
+
+	if err := store.DeleteRange(11, 65); err != nil {
+		t.Fatalf("DeleteRange failed: %v", err)
+	}
+	after, _ = filepath.Glob(filepath.Join(dir, "*.wal"))
+	if len(after) != 1 || after[0] != segments[1] {
+		t.Fatalf("Expected only %s to remain, got %v", segments[1], after)
+	}
+	store.Close()
+
+	store, err = raft.NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	defer store.Close()
+
+	first, _ := store.FirstIndex()
+	last, _ := store.LastIndex()
+	if first != 66 || last != 70 {
+		t.Fatalf("Expected log [66, 70], got [%d, %d]", first, last)
+	}
+	var entry raft.LogEntry
+	if err := store.GetLog(70, &entry); err != nil || entry.Command != value {
+		t.Fatalf("Unexpected entry 70: %v", err)
+	}
+	term, votedFor, _ := store.GetState()
+	if term != 3 || votedFor != 2 {
+		t.Fatalf("Expected term 3 votedFor 2, got %d %d", term, votedFor)
+	}
+}
+
+func TestFileStoreCountsAppendedBytes(t *testing.T) {
+	dir := t.TempDir()
+	writeTestWAL(t, dir)
+
+	// Воспроизведённые при открытии записи не считаются дописанными
+	store, err := raft.NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	defer store.Close()
+	if n := store.AppendedBytes(); n != 0 {
+		t.Fatalf("Expected 0 appended bytes after replay, got %d", n)
+	}
+
+	if err := store.StoreLogs([]raft.LogEntry{{Index: 4, Term: 2, Command: "d"}}); err != nil {
+		t.Fatalf("StoreLogs failed: %v", err)
+	}
+	first := store.AppendedBytes()
+	if first <= 0 {
+		t.Fatalf("Expected appended bytes to grow, got %d", first)
+	}
+	if err := store.SetState(3, 1); err != nil {
+		t.Fatalf("SetState failed: %v", err)
+	}
+	if n := store.AppendedBytes(); n != first {
+		t.Fatalf("Expected state records not to count, got %d want %d", n, first)
+	}
+}
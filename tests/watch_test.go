@@ -0,0 +1,113 @@
+package tests
+
+import (
+	"bytes"
+	"testing"
+
+	"raft-kv-store/pkg/kvstore"
+	"raft-kv-store/pkg/raft"
+)
+
+func TestWatchResumeFromRevision(t *testing.T) {
+	store := kvstore.NewStore(raft.NewRaftNode(1, []string{}))
+
+	store.ApplyLog(1, kvstore.Command{Op: kvstore.OpSet, Key: "app/a", Value: "1"})
+	store.ApplyLog(2, kvstore.Command{Op: kvstore.OpSet, Key: "other", Value: "x"})
+	store.ApplyLog(3, kvstore.Command{Op: kvstore.OpDelete, Key: "app/a"})
+
+	// Клиент видел событие с revision 1 и переподключается
+	w, err := store.Watch("app/", true, 2)
+	if err != nil {
+		t.Fatalf("Watch failed: %v", err)
+	}
+	defer w.Close()
+
+	store.ApplyLog(4, kvstore.Command{Op: kvstore.OpSet, Key: "app/b", Value: "2"})
+
+	want := []kvstore.Event{
+		{Type: kvstore.EventDelete, Key: "app/a", Revision: 3},
+		{Type: kvstore.EventPut, Key: "app/b", Value: "2", Revision: 4},
+	}
+	for _, expected := range want {
+		ev := <-w.Events()
+		if ev != expected {
+			t.Errorf("Expected %+v, got %+v", expected, ev)
+		}
+	}
+}
+
+// После восстановления из снапшота подписчик получает ErrWatchCancelled и
+// может продолжить с последней ревизии, а не ErrCompacted.
+func TestWatchCancelledOnRestore(t *testing.T) {
+	store := kvstore.NewStore(raft.NewRaftNode(1, []string{}))
+	store.ApplyLog(1, kvstore.Command{Op: kvstore.OpSet, Key: "app/a", Value: "1"})
+
+	data, err := store.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+
+	w, err := store.Watch("app/", true, 0)
+	if err != nil {
+		t.Fatalf("Watch failed: %v", err)
+	}
+	defer w.Close()
+
+	if err := store.Restore(bytes.NewReader(data)); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	for range w.Events() {
+	}
+	if w.Err() != kvstore.ErrWatchCancelled {
+		t.Fatalf("Expected ErrWatchCancelled, got %v", w.Err())
+	}
+}
+
+// События одной ревизии приходят в том же порядке, в каком их отдаёт
+// replay: иначе нельзя продолжить ревизию с середины.
+func TestWatchRevisionOrderMatchesReplay(t *testing.T) {
+	store := kvstore.NewStore(raft.NewRaftNode(1, []string{}))
+
+	live, err := store.Watch("app/", true, 0)
+	if err != nil {
+		t.Fatalf("Watch failed: %v", err)
+	}
+	defer live.Close()
+
+	store.ApplyLog(1, kvstore.Command{Op: kvstore.OpTxn, Success: []kvstore.Command{
+		{Op: kvstore.OpSet, Key: "app/c", Value: "3"},
+		{Op: kvstore.OpSet, Key: "app/a", Value: "1"},
+		{Op: kvstore.OpSet, Key: "app/b", Value: "2"},
+	}})
+
+	replay, err := store.Watch("app/", true, 1)
+	if err != nil {
+		t.Fatalf("Watch failed: %v", err)
+	}
+	defer replay.Close()
+
+	for _, key := range []string{"app/a", "app/b", "app/c"} {
+		got, want := <-live.Events(), <-replay.Events()
+		if got != want || got.Key != key {
+			t.Fatalf("Expected %s from both watchers, got live %+v, replay %+v", key, got, want)
+		}
+	}
+}
+
+func TestWatchRejectedAfterClose(t *testing.T) {
+	store := kvstore.NewStore(raft.NewRaftNode(1, []string{}))
+	w, err := store.Watch("k", false, 0)
+	if err != nil {
+		t.Fatalf("Watch failed: %v", err)
+	}
+
+	store.Close()
+	for range w.Events() {
+	}
+	if w.Err() != kvstore.ErrWatchCancelled {
+		t.Fatalf("Expected ErrWatchCancelled on close, got %v", w.Err())
+	}
+	if _, err := store.Watch("k", false, 0); err != kvstore.ErrWatchCancelled {
+		t.Fatalf("Expected ErrWatchCancelled after close, got %v", err)
+	}
+}